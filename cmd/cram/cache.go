@@ -0,0 +1,98 @@
+// Copyright 2016 Martin Geisler <martin@geisler.net>
+//
+// Cram is licensed under the MIT license, see the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// cacheDir is the directory in the current working directory where
+// the outcome of the last run of each test is kept.
+const cacheDir = ".cram-cache"
+
+// cacheFile is the name of the file with the outcomes in cacheDir.
+const cacheFile = "results.json"
+
+// Outcomes of a test recorded in the cache.
+const (
+	outcomePassed = "passed"
+	outcomeFailed = "failed"
+	outcomeError  = "error"
+	outcomeFlaky  = "flaky"
+)
+
+// cachedResult is the outcome of the last run of a test.
+type cachedResult struct {
+	Outcome     string  `json:"outcome"`
+	Duration    float64 `json:"duration"` // Seconds.
+	Fingerprint string  `json:"fingerprint,omitempty"`
+}
+
+// testCache maps the cleaned path of a test to its last result.
+type testCache map[string]cachedResult
+
+// readCache reads the cache in the current working directory. A
+// missing or invalid cache is treated as empty.
+func readCache() testCache {
+	cache := testCache{}
+	data, err := ioutil.ReadFile(filepath.Join(cacheDir, cacheFile))
+	if err == nil && json.Unmarshal(data, &cache) != nil {
+		cache = testCache{}
+	}
+	return cache
+}
+
+// write saves the cache in the current working directory.
+func (cache testCache) write() error {
+	if err := os.MkdirAll(cacheDir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(cacheDir, cacheFile),
+		append(data, '\n'), 0644)
+}
+
+// failed returns true if the test at path failed or had an error the
+// last time it was run.
+func (cache testCache) failed(path string) bool {
+	outcome := cache[filepath.Clean(path)].Outcome
+	return outcome == outcomeFailed || outcome == outcomeError
+}
+
+// passed returns true if the test at path passed the last time it
+// was run with the given fingerprint.
+func (cache testCache) passed(path, fingerprint string) bool {
+	cached := cache[filepath.Clean(path)]
+	return cached.Outcome == outcomePassed && cached.Fingerprint == fingerprint
+}
+
+// selectPaths returns the paths of the tests to run. With lastFailed,
+// only tests which failed last time are kept, unless the cache is
+// empty and all tests are kept. With failedFirst, these tests are
+// moved to the front. The order is otherwise kept.
+func selectPaths(paths []string, cache testCache, lastFailed,
+	failedFirst bool) []string {
+	if !lastFailed && !failedFirst || len(cache) == 0 {
+		return paths
+	}
+	failed, others := []string{}, []string{}
+	for _, path := range paths {
+		if cache.failed(path) {
+			failed = append(failed, path)
+		} else {
+			others = append(others, path)
+		}
+	}
+	if lastFailed {
+		return failed
+	}
+	return append(failed, others...)
+}
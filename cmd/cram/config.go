@@ -0,0 +1,234 @@
+// Copyright 2016 Martin Geisler <martin@geisler.net>
+//
+// Cram is licensed under the MIT license, see the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/kingpin"
+	"github.com/mgeisler/cram"
+)
+
+// configNames are the file names we look for when searching for a
+// configuration file. The names are tried in order in each
+// directory.
+var configNames = []string{".cramrc", "cram.toml"}
+
+// Setting holds the values given for a key in a configuration file.
+// Flags that can be repeated on the command line can have several
+// values.
+type Setting struct {
+	Values []string // Values for the setting.
+	Lineno int      // Line number of the setting.
+}
+
+// Config maps the long name of a command line flag to the setting
+// for it in a configuration file.
+type Config map[string]Setting
+
+type ConfigError struct {
+	Path   string // Path to configuration file.
+	Lineno int    // Line number of failure.
+	Msg    string // Error message.
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("%s:%d: %s", e.Path, e.Lineno, e.Msg)
+}
+
+// findConfig searches dir and its parent directories for a
+// configuration file. The empty string is returned if no file was
+// found.
+func findConfig(dir string) string {
+	for {
+		for _, name := range configNames {
+			path := filepath.Join(dir, name)
+			info, err := os.Stat(path)
+			if err == nil && !info.IsDir() {
+				return path
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// splitList splits the inside of a "[...]" array into its quoted
+// elements.
+func splitList(s string) (values []string, err error) {
+	for {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			return
+		}
+		if s[0] != '"' && s[0] != '\'' {
+			err = fmt.Errorf("expected quoted string, found %q", s)
+			return
+		}
+		// Find the closing quote, skipping escaped characters in
+		// double quoted strings.
+		end := 1
+		for end < len(s) && s[end] != s[0] {
+			if s[0] == '"' && s[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(s) {
+			err = fmt.Errorf("unterminated string %q", s)
+			return
+		}
+		value, e := parseValue(s[:end+1])
+		if e != nil {
+			err = e
+			return
+		}
+		values = append(values, value)
+		s = strings.TrimSpace(s[end+1:])
+		if s != "" {
+			if s[0] != ',' {
+				err = fmt.Errorf("expected ',' in list, found %q", s)
+				return
+			}
+			s = s[1:]
+		}
+	}
+}
+
+// parseValue parses a single value. Double quoted strings use the Go
+// (and TOML) escape sequences, single quoted strings are taken
+// literally, and everything else is used as is.
+func parseValue(s string) (string, error) {
+	l := len(s)
+	switch {
+	case l >= 2 && s[0] == '"' && s[l-1] == '"':
+		return strconv.Unquote(s)
+	case l >= 2 && s[0] == '\'' && s[l-1] == '\'':
+		return s[1 : l-1], nil
+	}
+	return s, nil
+}
+
+// parseConfig reads a configuration file. The format is a small
+// subset of TOML: each non-empty line is a comment starting with "#"
+// or a "key = value" pair. The value can be a bare word (such as a
+// number or a boolean), a quoted string, or a list of quoted strings
+// written as ["a", "b"].
+func parseConfig(r io.Reader, path string) (config Config, err error) {
+	config = make(Config)
+	scanner := bufio.NewScanner(r)
+	lineno := 0
+	for scanner.Scan() {
+		lineno++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		i := strings.IndexByte(line, '=')
+		if i < 0 {
+			err = &ConfigError{path, lineno,
+				fmt.Sprintf("Expected key = value, found %q", line)}
+			return
+		}
+		key := strings.TrimSpace(line[:i])
+		value := strings.TrimSpace(line[i+1:])
+
+		var values []string
+		l := len(value)
+		if l >= 2 && value[0] == '[' && value[l-1] == ']' {
+			values, err = splitList(value[1 : l-1])
+		} else {
+			value, err = parseValue(value)
+			values = []string{value}
+		}
+		if err != nil {
+			err = &ConfigError{path, lineno,
+				fmt.Sprintf("Invalid value for %s: %s", key, err)}
+			return
+		}
+		config[key] = Setting{values, lineno}
+	}
+	err = scanner.Err()
+	return
+}
+
+// readConfig parses the configuration file at path.
+func readConfig(path string) (Config, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+	return parseConfig(fp, path)
+}
+
+// applyConfig uses the values in config as defaults for the flags in
+// app. Flags given on the command line will thus override the values
+// from the configuration file.
+func applyConfig(app *kingpin.Application, config Config, path string) error {
+	for key, setting := range config {
+		flag := app.GetFlag(key)
+		if flag == nil || key == "help" || key == "version" {
+			return &ConfigError{path, setting.Lineno,
+				fmt.Sprintf("Unknown setting %q", key)}
+		}
+		flag.Default(setting.Values...)
+	}
+	return nil
+}
+
+// formatSetting formats the value of a flag in a way that can be
+// read back by parseConfig.
+func formatSetting(flag *kingpin.FlagModel) string {
+	if _, ok := flag.Value.(interface {
+		IsCumulative() bool
+	}); ok {
+		quoted := []string{}
+		if getter, ok := flag.Value.(kingpin.Getter); ok {
+			if values, ok := getter.Get().(*[]string); ok {
+				for _, value := range *values {
+					quoted = append(quoted, strconv.Quote(value))
+				}
+			}
+		}
+		return "[" + strings.Join(quoted, ", ") + "]"
+	}
+
+	value := flag.Value.String()
+	if flag.IsBoolFlag() {
+		return value
+	}
+	if _, err := strconv.Atoi(value); err == nil {
+		return value
+	}
+	return strconv.Quote(value)
+}
+
+// printConfig shows the effective settings in the configuration file
+// format.
+func printConfig(app *kingpin.Application, path string) {
+	if path == "" {
+		fmt.Println("# No configuration file found")
+	} else {
+		fmt.Println("# Configuration file:", path)
+	}
+	fmt.Println("# Output matchers:", strings.Join(cram.Matchers(), ", "))
+	for _, flag := range app.Model().Flags {
+		if flag.Hidden || flag.Name == "help" || flag.Name == "version" {
+			continue
+		}
+		fmt.Printf("%s = %s\n", flag.Name, formatSetting(flag))
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2016 Martin Geisler <martin@geisler.net>
+//
+// Cram is licensed under the MIT license, see the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/mgeisler/cram"
+)
+
+// formatFile formats the test file at path. The file is rewritten
+// unless check is set. The changed result is true if the formatted
+// file differs from the original.
+func formatFile(path string, check bool) (changed bool, err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	var buf bytes.Buffer
+	err = cram.Format(bytes.NewReader(data), &buf, path)
+	if err != nil {
+		return
+	}
+	changed = !bytes.Equal(data, buf.Bytes())
+	if changed && !check {
+		err = ioutil.WriteFile(path, buf.Bytes(), info.Mode().Perm())
+	}
+	return
+}
+
+// formatFiles formats the test files found by expanding args. The
+// paths of files which are (or would be) changed are printed. Only
+// whole files are formatted, so a "file.t:42" argument is an error.
+func formatFiles(args []string, opts Options, check bool) (error, int) {
+	paths := make(chan pathIndex, 8)
+	go expandArgs(args, readCache(), opts, paths)
+
+	testCount, changedCount, errCount := 0, 0, 0
+	for pi := range paths {
+		testCount++
+		if pi.Line > 0 {
+			fmt.Fprintf(os.Stderr, "%s:%d: Only whole files can be formatted\n",
+				pi.Path, pi.Line)
+			errCount++
+			continue
+		}
+		changed, err := formatFile(pi.Path, check)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			errCount++
+			continue
+		}
+		if changed {
+			fmt.Println(pi.Path)
+			changedCount++
+		}
+	}
+
+	msg := fmt.Sprintf("# Formatted %d of %d tests, %d errors",
+		changedCount, testCount, errCount)
+	if check {
+		msg = fmt.Sprintf("# %d of %d tests need formatting, %d errors",
+			changedCount, testCount, errCount)
+	}
+	exitCode := 0
+	if errCount > 0 {
+		exitCode = 2
+	} else if check && changedCount > 0 {
+		exitCode = 1
+	}
+	return errors.New(msg), exitCode
+}
@@ -0,0 +1,231 @@
+// Copyright 2016 Martin Geisler <martin@geisler.net>
+//
+// Cram is licensed under the MIT license, see the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mgeisler/cram"
+)
+
+// lintProblem is a mistake found in a test file by lintTest.
+type lintProblem struct {
+	Lineno int    // Line number of the problem.
+	Msg    string // Description of the problem.
+}
+
+type byLineno []lintProblem
+
+func (p byLineno) Len() int           { return len(p) }
+func (p byLineno) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p byLineno) Less(i, j int) bool { return p[i].Lineno < p[j].Lineno }
+
+var (
+	// exitCodeLine matches an "[n]" exit code line.
+	exitCodeLine = regexp.MustCompile(`^\[\d+\]$`)
+	// exitCmdLine matches a command that makes the shell exit.
+	exitCmdLine = regexp.MustCompile(`^exit(\s+\d+)?\s*;?\s*$`)
+	// lineSuffix matches an exit code or a " (name)" suffix
+	// followed by trailing whitespace.
+	lineSuffix = regexp.MustCompile(`(^\[\d+\]| \(([a-z-]+)[^()]*\))[ \t]+$`)
+	// nameSuffix matches a " (name)" suffix at the end of a line.
+	nameSuffix = regexp.MustCompile(` \(([a-z-]+)[^()]*\)$`)
+)
+
+// isSuffixName returns true if name is handled at the end of output
+// lines: either a registered matcher or "no-eol".
+func isSuffixName(name string) bool {
+	if name == "no-eol" {
+		return true
+	}
+	_, ok := cram.LookupMatcher(name)
+	return ok
+}
+
+// lintLines checks the raw lines of a test file for problems which
+// make the parser silently treat a line differently from what the
+// author intended.
+func lintLines(lines []string) (problems []lintProblem) {
+	for i, line := range lines {
+		lineno := i + 1
+		line = cram.DropEol(line)
+		text := strings.TrimLeft(line, " \t")
+		indent := line[:len(line)-len(text)]
+
+		switch {
+		case strings.Contains(indent, "\t"):
+			problems = append(problems, lintProblem{lineno,
+				"Indentation uses tabs instead of spaces"})
+			continue
+		case strings.HasPrefix(text, "$ ") && len(indent)%2 == 1:
+			// Commands indented by 1 space are commentary and
+			// commands indented by 3 spaces are output. Commands
+			// indented by 4 spaces are typically the output of a
+			// nested cram invocation.
+			problems = append(problems, lintProblem{lineno,
+				fmt.Sprintf("Command is indented by %d spaces instead of 2",
+					len(indent))})
+			continue
+		}
+
+		// Only output lines are matched against anything.
+		if !strings.HasPrefix(line, "  ") || strings.HasPrefix(line, "  $ ") ||
+			strings.HasPrefix(line, "  > ") {
+			continue
+		}
+		m := lineSuffix.FindStringSubmatch(line[2:])
+		if m != nil && (m[2] == "" || isSuffixName(m[2])) {
+			problems = append(problems, lintProblem{lineno,
+				fmt.Sprintf("Trailing whitespace after %q", m[1])})
+		}
+	}
+	return
+}
+
+// lintInput checks the input lines of a command. Before input lines
+// were supported, "  < " lines after a command were output lines, as
+// in the output of diff. Such lines are now passed to the command.
+func lintInput(cmd cram.Command) (problems []lintProblem) {
+	if len(cmd.Input) == 0 {
+		return
+	}
+	// Command.Lineno is the 0-based index of the first output
+	// line, which follows the input lines.
+	first := cmd.Lineno - len(cmd.Input) + 1
+	if len(cmd.ExpectedOutput) == 0 && cmd.ExpectedExitCode == 0 {
+		problems = append(problems, lintProblem{first,
+			"Input lines are not followed by output, escape \"<\" " +
+				"as \\x3c if they are output"})
+	}
+	for j, line := range cmd.Input {
+		line = cram.DropEol(line)
+		m := nameSuffix.FindStringSubmatch(line)
+		if m != nil && isSuffixName(m[1]) {
+			problems = append(problems, lintProblem{first + j,
+				fmt.Sprintf("Input line %q looks like output", line)})
+		}
+	}
+	return
+}
+
+// lintCmds checks the parsed commands for problems.
+func lintCmds(cmds []cram.Command) (problems []lintProblem) {
+	for i, cmd := range cmds {
+		problems = append(problems, lintInput(cmd)...)
+		for j, line := range cmd.ExpectedOutput {
+			// Command.Lineno is the 0-based index of the first
+			// output line.
+			lineno := cmd.Lineno + j + 1
+			line = cram.DropEol(line)
+			if exitCodeLine.MatchString(line) {
+				problems = append(problems, lintProblem{lineno,
+					fmt.Sprintf("Exit code %s is not on the last output line",
+						line)})
+			}
+			if strings.HasSuffix(line, " (re)") {
+				pattern := strings.TrimSuffix(line, " (re)")
+				if regexp.QuoteMeta(pattern) == pattern {
+					problems = append(problems, lintProblem{lineno,
+						fmt.Sprintf("Pattern %q has no special characters, "+
+							"the (re) suffix is not needed", pattern)})
+				}
+			}
+		}
+
+		if exitCmdLine.MatchString(cmd.CmdLine) && i+1 < len(cmds) {
+			next := cmds[i+1]
+			exitLineno := cmd.CmdLineno() + 1
+			nextLineno := next.CmdLineno() + 1
+			problems = append(problems, lintProblem{nextLineno,
+				fmt.Sprintf("Command is never executed due to exit on line %d",
+					exitLineno)})
+		}
+	}
+	return
+}
+
+// lintTest parses the test file at path and returns the problems
+// found in it. An error is returned if the file cannot be parsed or
+// has invalid directives. Only errors are checked for unless style
+// is set. With a line number in opts, only the problems of the
+// command on that line are returned.
+func lintTest(path string, opts cram.Options, style bool) (
+	[]lintProblem, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	test, err := cram.Validate(path, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []lintProblem
+	if style {
+		lines := strings.SplitAfter(string(data), "\n")
+		problems = append(lintLines(lines), lintCmds(test.Cmds)...)
+		sort.Stable(byLineno(problems))
+	}
+	if opts.Line == 0 {
+		return problems, nil
+	}
+	for _, cmd := range test.Cmds {
+		if !cmd.Covers(opts.Line) {
+			continue
+		}
+		var selected []lintProblem
+		for _, problem := range problems {
+			if cmd.Covers(problem.Lineno) {
+				selected = append(selected, problem)
+			}
+		}
+		return selected, nil
+	}
+	return nil, &cram.InvalidTestError{Path: path, Lineno: opts.Line,
+		Msg: "No command on this line"}
+}
+
+// lintFiles checks the test files found by expanding args, using
+// processOpts for validating them. With style set, the files are also
+// checked for mistakes which are not errors. The problems are printed
+// with their file and line number.
+func lintFiles(args []string, opts Options, processOpts cram.Options,
+	style bool) (error, int) {
+	paths := make(chan pathIndex, 8)
+	go expandArgs(args, readCache(), opts, paths)
+
+	testCount, problemCount, errCount := 0, 0, 0
+	for pi := range paths {
+		testCount++
+		processOpts := processOpts
+		processOpts.Line = pi.Line
+		problems, err := lintTest(pi.Path, processOpts, style)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			errCount++
+			continue
+		}
+		for _, problem := range problems {
+			fmt.Printf("%s:%d: %s\n", pi.Path, problem.Lineno, problem.Msg)
+		}
+		problemCount += len(problems)
+	}
+
+	msg := fmt.Sprintf("# Linted %d tests, %d problems, %d errors",
+		testCount, problemCount, errCount)
+	exitCode := 0
+	if errCount > 0 {
+		exitCode = 2
+	} else if problemCount > 0 {
+		exitCode = 1
+	}
+	return errors.New(msg), exitCode
+}
@@ -8,6 +8,7 @@ import (
 	"bufio"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -15,6 +16,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/alecthomas/kingpin"
 	"github.com/kylelemons/godebug/diff"
@@ -30,89 +32,158 @@ import (
 //   $ echo "x\ny" | cram --interactive
 var stdinReader = bufio.NewReader(os.Stdin)
 
-func booleanPrompt(prompt string) (bool, error) {
-	for {
-		fmt.Print(prompt, " ")
-		answer, err := stdinReader.ReadString('\n')
-		if err != nil {
-			return false, err
+// acceptFailure decides if the actual output of a failed command
+// should be written to the test file without asking. With --update
+// all changes are accepted and with --fill-empty changes to commands
+// without expected output or exit code are accepted.
+func acceptFailure(cmd cram.ExecutedCommand, opts Options) bool {
+	return opts.Update || opts.FillEmpty &&
+		len(cmd.ExpectedOutput) == 0 && cmd.ExpectedExitCode == 0
+}
+
+// reportViolations reports the commands killed by a resource limit
+// before the given line number, or all commands for a negative line
+// number. They are reported without a diff since their output is not
+// worth keeping. The remaining violations are returned.
+func reportViolations(violations []cram.LimitViolation,
+	lineno int) []cram.LimitViolation {
+	for len(violations) > 0 && (lineno < 0 || violations[0].Lineno < lineno) {
+		v := violations[0]
+		fmt.Printf("When executing %+#v:\n", cram.DropEol(v.CmdLine))
+		fmt.Printf("Killed for exceeding the %s limit\n", v.Limit)
+		violations = violations[1:]
+	}
+	return violations
+}
+
+// printFailure shows the difference between the expected and actual
+// output of a failed command.
+func printFailure(cmd cram.ExecutedCommand) {
+	fmt.Printf("When executing %+#v:\n", cram.DropEol(cmd.CmdLine))
+
+	expected := cmd.ExpectedOutput
+	actual := cmd.ActualOutput
+
+	if cmd.ActualExitCode != 0 {
+		line := fmt.Sprintf("[%d]\n", cmd.ActualExitCode)
+		actual = append(actual, line)
+	}
+	if cmd.ExpectedExitCode != 0 {
+		line := fmt.Sprintf("[%d]\n", cmd.ExpectedExitCode)
+		expected = append(expected, line)
+	}
+
+	chunks := diff.DiffChunks(expected, actual)
+	for _, chunk := range chunks {
+		for _, line := range chunk.Added {
+			fmt.Printf("+%s", line)
 		}
-		answer = strings.ToLower(strings.TrimSpace(answer))
-		switch answer {
-		case "y", "yes":
-			return true, nil
-		case "n", "no":
-			return false, nil
-		default:
-			fmt.Println("Please answer 'yes' or 'no'")
+		for _, line := range chunk.Deleted {
+			fmt.Printf("-%s", line)
+		}
+		for _, line := range chunk.Equal {
+			fmt.Printf(" %s", line)
+		}
+	}
+}
+
+// printAttempts shows the failures of the earlier attempts of
+// retried tests. Only the last attempt can be patched.
+func printAttempts(tests []cram.ExecutedTest) {
+	for _, test := range tests {
+		for i, attempt := range test.Attempts {
+			fmt.Printf("# Attempt %d of %s failed:\n", i+1, test.Path)
+			violations := attempt.Violations
+			for _, cmd := range attempt.Failures {
+				violations = reportViolations(violations, cmd.Lineno)
+				printFailure(cmd)
+			}
+			reportViolations(violations, -1)
 		}
 	}
 }
 
-func processFailures(tests []cram.ExecutedTest, interactive bool) (
+// processFailures shows the failed commands and patches the test
+// files with the changes accepted. Errors are printed when they
+// happen and the last error is returned.
+func processFailures(tests []cram.ExecutedTest, opts Options) (
 	err error) {
 
+	quit := false
 	for _, test := range tests {
 		var needPatching []cram.ExecutedCommand
+		// The remaining changes in the file are accepted or
+		// skipped when the user answers "a" or "d".
+		acceptRest, skipRest := false, false
 
-		for _, cmd := range test.Failures {
-			fmt.Printf("When executing %+#v:\n", cram.DropEol(cmd.CmdLine))
+		violations := test.Violations
 
-			expected := cmd.ExpectedOutput
-			actual := cmd.ActualOutput
+		for _, cmd := range test.Failures {
+			if quit {
+				break
+			}
+			violations = reportViolations(violations, cmd.Lineno)
+			printFailure(cmd)
 
-			if cmd.ActualExitCode != 0 {
-				line := fmt.Sprintf("[%d]\n", cmd.ActualExitCode)
-				actual = append(actual, line)
+			if acceptFailure(cmd, opts) || acceptRest {
+				needPatching = append(needPatching, cmd)
+				continue
 			}
-			if cmd.ExpectedExitCode != 0 {
-				line := fmt.Sprintf("[%d]\n", cmd.ExpectedExitCode)
-				expected = append(expected, line)
+			if !opts.Interactive || skipRest {
+				continue
 			}
 
-			chunks := diff.DiffChunks(expected, actual)
-			for _, chunk := range chunks {
-				for _, line := range chunk.Added {
-					fmt.Printf("+%s", line)
-				}
-				for _, line := range chunk.Deleted {
-					fmt.Printf("-%s", line)
-				}
-				for _, line := range chunk.Equal {
-					fmt.Printf(" %s", line)
+		prompt:
+			for {
+				answer, e := reviewPrompt()
+				if e == io.EOF {
+					// Running out of answers is the same as
+					// quitting.
+					answer, e = reviewQuit, nil
 				}
-			}
-
-			if interactive {
-				accept, e := booleanPrompt("Accept this change?")
 				if e != nil {
 					err = e
+					fmt.Fprintln(os.Stderr, err)
 					return
 				}
-				if accept {
+				switch answer {
+				case reviewAccept:
 					needPatching = append(needPatching, cmd)
+				case reviewAcceptFile:
+					needPatching = append(needPatching, cmd)
+					acceptRest = true
+				case reviewSkipFile:
+					skipRest = true
+				case reviewQuit:
+					quit = true
+				case reviewEdit:
+					edited, e := editOutput(cmd)
+					if e != nil {
+						fmt.Println(e)
+						continue prompt
+					}
+					needPatching = append(needPatching, edited)
+				case reviewContext:
+					if e := showContext(test.Path, cmd); e != nil {
+						fmt.Println(e)
+					}
+					continue prompt
 				}
+				break
 			}
 		}
 
-		if needPatching != nil {
-			input, e := os.Open(test.Path)
-			if err = e; err != nil {
-				return
-			}
+		if !quit {
+			reportViolations(violations, -1)
+		}
 
-			outPath := test.Path + ".patched"
-			output, e := os.Create(outPath)
-			if err = e; err != nil {
-				return
-			}
-			err = cram.Patch(input, output, needPatching)
-			if err != nil {
-				return
-			}
-			err = os.Rename(outPath, test.Path)
-			if err != nil {
-				return
+		if needPatching != nil {
+			e := cram.PatchFile(test.Path, needPatching, opts.Backup)
+			if e != nil {
+				// Keep patching the remaining files.
+				err = fmt.Errorf("Could not patch %s: %s", test.Path, e)
+				fmt.Fprintln(os.Stderr, err)
+				continue
 			}
 			fmt.Println("Patched", test.Path)
 		}
@@ -121,59 +192,119 @@ func processFailures(tests []cram.ExecutedTest, interactive bool) (
 	return
 }
 
-// Wrapper for the return type of cram.Process.
+// Wrapper for the return type of cram.Process. The line is the line
+// number the test was run up to, or zero if it was run completely.
 type processResult struct {
-	Test cram.ExecutedTest
-	Err  error
+	Test     cram.ExecutedTest
+	Err      error
+	Duration time.Duration
+	Line     int
 }
 
-// Wrapper for a path and an index.
+// Wrapper for a path and an index. The line is the line number given
+// with the path on the command line, or zero if none was given.
 type pathIndex struct {
 	Path string
 	Idx  int
+	Line int
 }
 
-// Options describe the command line options. They are parsed in main
-// and passed to run.
+// Options describe the command line options. They are parsed in main,
+// using the configuration file (if any) for defaults, and passed to
+// run.
 type Options struct {
-	Jobs        int
-	KeepTmp     bool
-	Interactive bool
-	Verbose     bool
-	Debug       bool
+	Jobs          int
+	KeepTmp       bool
+	Interactive   bool
+	Verbose       bool
+	Debug         bool
+	Substitutions []string
+	Lint          bool
+	Update        bool
+	FillEmpty     bool
+	Backup        bool
+	CRLF          bool
+	Pty           bool
+	StripAnsi     bool
+	Limits        []string
+	Sandbox       bool
+	Retries       int
+	LastFailed    bool
+	FailedFirst   bool
+	Cache         bool
+	Depends       []string
+	Keyword       string
 }
 
-// processPath runs cram.Process on the paths in the paths channel.
-// The results (and any errors) are fed to the results channel.
-func processPath(jobs *sync.WaitGroup, tempdir string,
+// processPath runs the process function (normally a wrapper for
+// cram.Process) on the paths in the paths channel. The results (and
+// any errors) are fed to the results channel.
+func processPath(jobs *sync.WaitGroup,
+	process func(pi pathIndex) (cram.ExecutedTest, error),
 	paths chan pathIndex, results chan processResult) {
 	for pi := range paths {
-		result, err := cram.Process(tempdir, pi.Path, pi.Idx)
-		results <- processResult{result, err}
+		start := time.Now()
+		result, err := process(pi)
+		results <- processResult{result, err, time.Since(start), pi.Line}
 	}
 	jobs.Done()
 }
 
+// splitLine splits a "file.t:42" argument into the path and the line
+// number. The argument is returned unchanged with a zero line number
+// if it names an existing file or has no line number.
+func splitLine(arg string) (string, int) {
+	i := strings.LastIndex(arg, ":")
+	if i < 0 {
+		return arg, 0
+	}
+	if _, err := os.Lstat(arg); err == nil {
+		return arg, 0
+	}
+	line, err := strconv.Atoi(arg[i+1:])
+	if err != nil || line < 1 {
+		return arg, 0
+	}
+	return arg[:i], line
+}
+
+// matchKeyword returns true if the path contains the pattern. A
+// pattern with glob characters must instead match the path or the
+// base name of the path. An empty pattern matches all paths.
+func matchKeyword(path, pattern string) bool {
+	if !strings.ContainsAny(pattern, "*?[") {
+		return strings.Contains(path, pattern)
+	}
+	if ok, _ := filepath.Match(pattern, path); ok {
+		return true
+	}
+	ok, _ := filepath.Match(pattern, filepath.Base(path))
+	return ok
+}
+
 // expandArgs turns command line arguments into pathIndex elements.
 // Directories are walked recursively and .t files found inside them
 // are added to the paths channel. Files on the command line are added
-// to paths directly.
-func expandArgs(args []string, paths chan pathIndex) {
-	// Index passed to cram.Process. Incremented when a pathIndex
-	// is added to paths by the walker or the loop below.
-	idx := 0
+// to paths directly, with the line number from a "file.t:42"
+// argument. Paths not matching the --keyword pattern are skipped. The
+// outcomes in the cache are used to select and order the paths with
+// --last-failed and --failed-first.
+func expandArgs(args []string, cache testCache, opts Options,
+	paths chan pathIndex) {
+	found := []string{}
+	lines := map[string]int{}
 
 	walker := func(path string, info os.FileInfo, err error) error {
 		// Add the path if there is an error (we want the error
 		// from cram.Process) of if it is a .t file.
 		if err != nil || !info.IsDir() && filepath.Ext(path) == ".t" {
-			paths <- pathIndex{path, idx}
-			idx++
+			found = append(found, path)
 		}
 		return nil
 	}
 
-	for _, path := range args {
+	for _, arg := range args {
+		path, line := splitLine(arg)
 		// We want different behavior for files and directories
 		// mentioned on the command line: files should be
 		// processed regardless of their extension, directories
@@ -184,17 +315,74 @@ func expandArgs(args []string, paths chan pathIndex) {
 
 		if err == nil && !info.IsDir() {
 			// Add the path regardless of file extension.
-			paths <- pathIndex{path, idx}
-			idx++
+			found = append(found, path)
+			if line > 0 {
+				lines[path] = line
+			}
 		} else {
 			// Let the walk function deal with the path.
 			filepath.Walk(path, walker)
 		}
 	}
+
+	matching := []string{}
+	for _, path := range found {
+		if matchKeyword(path, opts.Keyword) {
+			matching = append(matching, path)
+		}
+	}
+
+	// Index passed to cram.Process. The index follows the order
+	// the paths are run in.
+	selected := selectPaths(matching, cache, opts.LastFailed,
+		opts.FailedFirst)
+	for idx, path := range selected {
+		paths <- pathIndex{path, idx, lines[path]}
+	}
 	close(paths)
 }
 
+// makeProcessOptions turns the command line options into the
+// options for processing a test file.
+func makeProcessOptions(opts Options) (processOpts cram.Options, err error) {
+	for _, rule := range opts.Substitutions {
+		sub, err := cram.ParseSubstitution(rule)
+		if err != nil {
+			return processOpts, err
+		}
+		processOpts.Substitutions = append(processOpts.Substitutions, sub)
+	}
+	if opts.CRLF {
+		processOpts.ParseMode = cram.CRLFEol
+	}
+	processOpts.Pty = opts.Pty
+	processOpts.StripAnsi = opts.StripAnsi
+	processOpts.Sandbox = opts.Sandbox
+	processOpts.Depends = opts.Depends
+	for _, rule := range opts.Limits {
+		if err = processOpts.Limits.Set(rule); err != nil {
+			return
+		}
+	}
+	return
+}
+
 func run(args []string, opts Options) (error, int) {
+	processOpts, err := makeProcessOptions(opts)
+	if err != nil {
+		return err, 2
+	}
+	if opts.Lint {
+		// Only parse the files and check them for errors.
+		return lintFiles(args, opts, processOpts, false)
+	}
+	if opts.Sandbox {
+		// Fail early instead of reporting an error for each test.
+		if err := cram.CheckSandbox(); err != nil {
+			return err, 2
+		}
+	}
+
 	tempdir, err := ioutil.TempDir("", "cram-")
 	if err != nil {
 		msg := "Could not create temp directory: " + err.Error()
@@ -208,6 +396,9 @@ func run(args []string, opts Options) (error, int) {
 
 	errCount, cmdCount, resultCount := 0, 0, 0
 	failures := []cram.ExecutedTest{}
+	// Tests which failed at least once when retried.
+	retried := []cram.ExecutedTest{}
+	flakyCount, cachedCount := 0, 0
 
 	// Number of goroutines to process the test files. We default to 2
 	// times the number of cores in the main function below.
@@ -215,6 +406,46 @@ func run(args []string, opts Options) (error, int) {
 		opts.Jobs = 1
 	}
 
+	// The cache is read before the tests are run. The outcomes of
+	// this run are recorded in a copy.
+	cache := readCache()
+	updated := testCache{}
+	for path, cached := range cache {
+		updated[path] = cached
+	}
+
+	process := func(pi pathIndex) (cram.ExecutedTest, error) {
+		// A test run up to a single command is never cached.
+		processOpts := processOpts
+		processOpts.Line = pi.Line
+		fingerprint := ""
+		if opts.Cache && pi.Line == 0 {
+			// Errors are reported when the test is run below.
+			fingerprint, _ = cram.Fingerprint(pi.Path, processOpts)
+			if fingerprint != "" && cache.passed(pi.Path, fingerprint) {
+				test, err := cram.Validate(pi.Path, processOpts)
+				return cram.ExecutedTest{Test: test, Cached: true,
+					Fingerprint: fingerprint}, err
+			}
+		}
+
+		result, err := cram.Process(tempdir, pi.Path, pi.Idx, processOpts)
+		// Failed tests are retried in a fresh directory for each
+		// attempt. The working directories keep their names.
+		for i := 1; i <= opts.Retries && err == nil && result.Failed(); i++ {
+			dir := filepath.Join(tempdir, fmt.Sprintf("retry-%d", i))
+			if err = os.MkdirAll(dir, 0700); err != nil {
+				break
+			}
+			retry, e := cram.Process(dir, pi.Path, pi.Idx, processOpts)
+			attempts := result.Attempts
+			result.Attempts = nil
+			retry.Attempts = append(attempts, result)
+			result, err = retry, e
+		}
+		result.Fingerprint = fingerprint
+		return result, err
+	}
 	// Input and result channels with space for a few items before we
 	// block.
 	paths := make(chan pathIndex, 8)
@@ -226,12 +457,12 @@ func run(args []string, opts Options) (error, int) {
 	jobs.Add(opts.Jobs)
 
 	// Expand the command line arguments into pathIndex elements.
-	go expandArgs(args, paths)
+	go expandArgs(args, cache, opts, paths)
 
 	// Start the worker goroutines that will process the test files
 	// found by expandArgs.
 	for i := 0; i < opts.Jobs; i++ {
-		go processPath(&jobs, tempdir, paths, results)
+		go processPath(&jobs, process, paths, results)
 	}
 
 	// Close the results channel when done.
@@ -251,6 +482,10 @@ func run(args []string, opts Options) (error, int) {
 		}
 
 		cmdCount += len(test.Cmds)
+		outcome := outcomePassed
+		if len(test.Attempts) > 0 {
+			retried = append(retried, test)
+		}
 
 		switch {
 		case err != nil:
@@ -266,14 +501,33 @@ func run(args []string, opts Options) (error, int) {
 				fmt.Print("E")
 			}
 			errCount++
-		case len(test.Failures) > 0:
+			outcome = outcomeError
+		case test.Failed():
 			if opts.Verbose {
-				fmt.Printf("F %s: %d of %d commands failed\n",
-					test.Path, len(test.Failures), len(test.Cmds))
+				fmt.Printf("F %s: %d of %d commands failed\n", test.Path,
+					len(test.Failures)+len(test.Violations), len(test.Cmds))
 			} else {
 				fmt.Print("F")
 			}
 			failures = append(failures, test)
+			outcome = outcomeFailed
+		case test.Flaky():
+			if opts.Verbose {
+				fmt.Printf("f %s: %d commands passed after %d retries\n",
+					test.Path, len(test.Cmds), len(test.Attempts))
+			} else {
+				fmt.Print("f")
+			}
+			flakyCount++
+			outcome = outcomeFlaky
+		case test.Cached:
+			if opts.Verbose {
+				fmt.Printf("c %s: %d commands passed before, not run\n",
+					test.Path, len(test.Cmds))
+			} else {
+				fmt.Print("c")
+			}
+			cachedCount++
 		default:
 			if opts.Verbose {
 				fmt.Printf(". %s: %d commands passed\n",
@@ -282,16 +536,37 @@ func run(args []string, opts Options) (error, int) {
 				fmt.Print(".")
 			}
 		}
+		if outcome == outcomePassed && result.Line > 0 {
+			// The commands after the line were not run, so
+			// the test as a whole has not passed.
+			continue
+		}
+		cached := cachedResult{outcome, result.Duration.Seconds(), ""}
+		if outcome == outcomePassed {
+			cached.Fingerprint = test.Fingerprint
+		}
+		updated[filepath.Clean(test.Path)] = cached
 	}
 	fmt.Print("\n")
 
-	processFailures(failures, opts.Interactive)
+	if err := updated.write(); err != nil {
+		fmt.Fprintln(os.Stderr, "Could not write cache:", err)
+	}
+
+	printAttempts(retried)
+	patchErr := processFailures(failures, opts)
 
 	msg := fmt.Sprintf("# Ran %d tests (%d commands), %d errors, %d failures",
 		resultCount, cmdCount, errCount, len(failures))
+	if flakyCount > 0 {
+		msg += fmt.Sprintf(", %d flaky", flakyCount)
+	}
+	if cachedCount > 0 {
+		msg += fmt.Sprintf(", %d cached", cachedCount)
+	}
 
 	exitCode := 0
-	if errCount > 0 {
+	if errCount > 0 || patchErr != nil {
 		exitCode = 2
 	} else if len(failures) > 0 {
 		exitCode = 1
@@ -319,15 +594,162 @@ func main() {
 		Short('j').
 		Default(strconv.Itoa(2 * runtime.NumCPU())).
 		Int()
-	paths := kingpin.
+	substitutions := kingpin.
+		Flag("substitute", "replace output matching s/regexp/replacement/").
+		PlaceHolder("RULE").
+		Strings()
+	lint := kingpin.
+		Flag("lint", "check test files for errors without running them").
+		Bool()
+	update := kingpin.
+		Flag("update", "update test files with the actual output").
+		Short('u').
+		Bool()
+	fillEmpty := kingpin.
+		Flag("fill-empty", "add the actual output to commands without output").
+		Bool()
+	backup := kingpin.
+		Flag("backup", "keep the original of patched test files as .orig").
+		Bool()
+	crlf := kingpin.
+		Flag("crlf", "treat CRLF in test files as line endings").
+		Bool()
+	pty := kingpin.
+		Flag("pty", "run commands with stdout and stderr on a terminal").
+		Bool()
+	stripAnsi := kingpin.
+		Flag("strip-ansi", "remove ANSI color codes from the output").
+		Bool()
+	limits := kingpin.
+		Flag("limit", "set a resource limit such as cpu=10 or fsize=1M").
+		PlaceHolder("LIMIT").
+		Strings()
+	sandbox := kingpin.
+		Flag("sandbox", "run commands in a sandbox without network access").
+		Bool()
+	retries := kingpin.
+		Flag("retries", "run failed tests up to N more times").
+		PlaceHolder("N").
+		Int()
+	lastFailed := kingpin.
+		Flag("last-failed", "only run the tests which failed in the last run").
+		Bool()
+	failedFirst := kingpin.
+		Flag("failed-first", "run the tests which failed in the last run first").
+		Bool()
+	cacheResults := kingpin.
+		Flag("cache", "skip tests which passed before with the same inputs").
+		Bool()
+	depends := kingpin.
+		Flag("depends", "program the tests depend on, for --cache").
+		PlaceHolder("PROGRAM").
+		Strings()
+	keyword := kingpin.
+		Flag("keyword", "only run test files with paths matching PATTERN").
+		Short('k').
+		PlaceHolder("PATTERN").
+		String()
+
+	runCmd := kingpin.
+		Command("run", "run test files (the default command)").
+		Default()
+	paths := runCmd.
+		Arg("path", "test files or directories, use file.t:N for line N").
+		Default(".").
+		Strings()
+	configCmd := kingpin.
+		Command("config", "show the effective configuration")
+	lintCmd := kingpin.
+		Command("lint", "check test files for common mistakes")
+	lintPaths := lintCmd.
 		Arg("path", "test files or directories").
 		Default(".").
 		Strings()
+	fmtCmd := kingpin.
+		Command("fmt", "rewrite test files in canonical format")
+	fmtCheck := fmtCmd.
+		Flag("check", "only list files which are not formatted").
+		Bool()
+	fmtPaths := fmtCmd.
+		Arg("path", "test files or directories").
+		Default(".").
+		Strings()
+	recordCmd := kingpin.
+		Command("record", "record commands typed in a shell as a new test file")
+	recordPath := recordCmd.
+		Arg("file", "test file to create").
+		Required().
+		String()
 
 	kingpin.Version("cram version 0.0.0")
-	kingpin.Parse()
 
-	opts := Options{*jobs, *keepTmp, *interactive, *verbose, *debug}
+	// Settings from a configuration file become the defaults for
+	// the corresponding flags.
+	configPath := ""
+	if cwd, err := os.Getwd(); err == nil {
+		configPath = findConfig(cwd)
+	}
+	if configPath != "" {
+		config, err := readConfig(configPath)
+		if err == nil {
+			err = applyConfig(kingpin.CommandLine, config, configPath)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
+	command := kingpin.Parse()
+	if command == configCmd.FullCommand() {
+		printConfig(kingpin.CommandLine, configPath)
+		return
+	}
+	opts := Options{
+		Jobs:          *jobs,
+		KeepTmp:       *keepTmp,
+		Interactive:   *interactive,
+		Verbose:       *verbose,
+		Debug:         *debug,
+		Substitutions: *substitutions,
+		Lint:          *lint,
+		Update:        *update,
+		FillEmpty:     *fillEmpty,
+		Backup:        *backup,
+		CRLF:          *crlf,
+		Pty:           *pty,
+		StripAnsi:     *stripAnsi,
+		Limits:        *limits,
+		Sandbox:       *sandbox,
+		Retries:       *retries,
+		LastFailed:    *lastFailed,
+		FailedFirst:   *failedFirst,
+		Cache:         *cacheResults,
+		Depends:       *depends,
+		Keyword:       *keyword,
+	}
+	if command == lintCmd.FullCommand() {
+		// The lint command checks the same as the --lint flag
+		// and looks for mistakes which are not errors.
+		processOpts, err := makeProcessOptions(opts)
+		exitCode := 2
+		if err == nil {
+			err, exitCode = lintFiles(*lintPaths, opts, processOpts, true)
+		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitCode)
+	}
+	if command == fmtCmd.FullCommand() {
+		err, exitCode := formatFiles(*fmtPaths, opts, *fmtCheck)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitCode)
+	}
+	if command == recordCmd.FullCommand() {
+		err, exitCode := record(*recordPath)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitCode)
+	}
+
 	err, exitCode := run(*paths, opts)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
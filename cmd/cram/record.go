@@ -0,0 +1,179 @@
+// Copyright 2016 Martin Geisler <martin@geisler.net>
+//
+// Cram is licensed under the MIT license, see the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/mgeisler/cram"
+	"github.com/mgeisler/cram/pty"
+	"github.com/satori/go.uuid"
+)
+
+// readCommand reads a command line from stdin. Lines ending with a
+// backslash are continued on the next line. The prompts are only
+// shown when stdin is a terminal.
+func readCommand(interactive bool) (string, error) {
+	cmdLine := ""
+	prompt := "$ "
+	for {
+		if interactive {
+			fmt.Print(prompt)
+		}
+		line, err := stdinReader.ReadString('\n')
+		if err != nil {
+			if err == io.EOF && line != "" {
+				line += "\n"
+			} else {
+				return "", err
+			}
+		}
+		cmdLine += line
+		if !strings.HasSuffix(line, "\\\n") {
+			return cmdLine, nil
+		}
+		prompt = "> "
+	}
+}
+
+// readOutput reads the output of a command from the shell until the
+// banner is found. The output is shown on stdout as it arrives.
+func readOutput(reader *bufio.Reader, banner string) ([]byte, error) {
+	var output bytes.Buffer
+	for {
+		line, err := reader.ReadString('\n')
+		output.WriteString(line)
+		if strings.HasSuffix(line, banner+"\n") {
+			// Show output without a final EOL on a line of its own.
+			if i := strings.LastIndex(line, "--- CRAM "); i > 0 {
+				fmt.Println(line[:i])
+			}
+			return output.Bytes(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		fmt.Print(line)
+	}
+}
+
+// record runs the commands read from stdin in a shell and writes
+// them to a new test file at path together with their output and
+// exit codes. The output of the commands is written to a
+// pseudo-terminal.
+func record(path string) (error, int) {
+	if _, err := os.Lstat(path); err == nil {
+		return fmt.Errorf("%s: file already exists", path), 2
+	}
+
+	tempdir, err := ioutil.TempDir("", "cram-")
+	if err != nil {
+		msg := "Could not create temp directory: " + err.Error()
+		return errors.New(msg), 2
+	}
+	defer os.RemoveAll(tempdir)
+	base := filepath.Base(path)
+	workdir := filepath.Join(tempdir, base[:len(base)-len(filepath.Ext(base))])
+	if err = os.Mkdir(workdir, 0700); err != nil {
+		return err, 2
+	}
+
+	env, err := cram.MakeEnvironment(path)
+	if err != nil {
+		return err, 2
+	}
+	subs, err := cram.MakePathSubstitutions(tempdir, workdir, path)
+	if err != nil {
+		return err, 2
+	}
+
+	master, slave, err := pty.Open()
+	if err != nil {
+		return err, 2
+	}
+	defer master.Close()
+
+	// The shell reads the commands from file descriptor 3. Commands
+	// reading stdin thus get end of file instead of the commands
+	// typed after them.
+	script, stdin, err := os.Pipe()
+	if err != nil {
+		return err, 2
+	}
+	shell := exec.Command("/bin/sh", "/dev/fd/3")
+	shell.Dir = workdir
+	shell.Env = env
+	shell.ExtraFiles = []*os.File{script}
+	pty.Setup(shell, slave)
+	err = shell.Start()
+	// Only the shell should keep the slave open. This makes reads
+	// from the master fail once the shell exits.
+	slave.Close()
+	script.Close()
+	if err != nil {
+		stdin.Close()
+		return err, 2
+	}
+	io.WriteString(stdin, "exec 3<&-\n")
+
+	banner := cram.MakeBanner(uuid.NewV4())
+	reader := bufio.NewReader(master)
+	interactive := pty.IsTerminal(os.Stdin)
+	doc := cram.Document{Path: path}
+	for {
+		cmdLine, err := readCommand(interactive)
+		if err != nil {
+			break
+		}
+		if strings.TrimSpace(cmdLine) == "" {
+			continue
+		}
+
+		cmds := []cram.Command{{CmdLine: cmdLine}}
+		script := strings.Join(cram.MakeScript(cmds, banner), "")
+		if _, err = io.WriteString(stdin, script); err != nil {
+			break
+		}
+		output, err := readOutput(reader, banner)
+		if err != nil {
+			// The shell exited before the command finished.
+			break
+		}
+		executed, err := cram.ParseOutput(cmds, output, banner, subs)
+		if err != nil || len(executed) != 1 {
+			break
+		}
+		cmd := executed[0]
+		if cmd.ActualExitCode != 0 {
+			fmt.Printf("[%d]\n", cmd.ActualExitCode)
+		}
+		doc.AppendCommand(cmdLine, cmd.ActualOutput, cmd.ActualExitCode)
+	}
+	if interactive {
+		fmt.Println()
+	}
+	stdin.Close()
+	shell.Wait()
+
+	fp, err := os.Create(path)
+	if err != nil {
+		return err, 2
+	}
+	defer fp.Close()
+	if err = doc.Write(fp); err != nil {
+		return err, 2
+	}
+	msg := fmt.Sprintf("# Recorded %d commands in %s", len(doc.Blocks), path)
+	return errors.New(msg), 0
+}
@@ -0,0 +1,161 @@
+// Copyright 2016 Martin Geisler <martin@geisler.net>
+//
+// Cram is licensed under the MIT license, see the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/mgeisler/cram"
+)
+
+// Answers to the review prompt.
+const (
+	reviewAccept     = "y"
+	reviewSkip       = "n"
+	reviewAcceptFile = "a"
+	reviewSkipFile   = "d"
+	reviewQuit       = "q"
+	reviewEdit       = "e"
+	reviewContext    = "c"
+)
+
+const reviewHelp = `y - accept this change
+n - skip this change
+a - accept this and all remaining changes in the file
+d - skip this and all remaining changes in the file
+q - quit, keeping the changes accepted so far
+e - edit the expected output in $EDITOR
+c - show the test file around the command
+? - show this help
+`
+
+// contextLines is the number of lines shown before and after a
+// command when the user asks for context.
+const contextLines = 3
+
+// reviewPrompt asks the user what to do about a change. The answer
+// is one of the review constants above.
+func reviewPrompt() (string, error) {
+	for {
+		fmt.Print("Accept this change [y,n,a,d,q,e,c,?]? ")
+		answer, err := stdinReader.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		switch answer {
+		case "yes":
+			return reviewAccept, nil
+		case "no":
+			return reviewSkip, nil
+		case reviewAccept, reviewSkip, reviewAcceptFile, reviewSkipFile,
+			reviewQuit, reviewEdit, reviewContext:
+			return answer, nil
+		case "?":
+			fmt.Print(reviewHelp)
+		default:
+			fmt.Println("Please answer one of y, n, a, d, q, e, c, " +
+				"or ? for help")
+		}
+	}
+}
+
+// showContext prints the lines of the test file around the command.
+func showContext(path string, cmd cram.ExecutedCommand) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+
+	first := cmd.CmdLineno()
+	last := cmd.Lineno + len(cmd.ExpectedOutput)
+	if cmd.ExpectedExitCode != 0 {
+		last++
+	}
+
+	start, end := first-contextLines, last+contextLines
+	if start < 0 {
+		start = 0
+	}
+	if end > len(lines) {
+		end = len(lines)
+	}
+	// Like grep -n, the lines of the command are marked with ":"
+	// and the surrounding lines with "-".
+	for i := start; i < end; i++ {
+		sep := "-"
+		if first <= i && i < last {
+			sep = ":"
+		}
+		fmt.Printf("%4d%s %s\n", i+1, sep, cram.DropEol(lines[i]))
+	}
+	return nil
+}
+
+// editOutput lets the user edit the actual output of the command in
+// $EDITOR. The result is a command whose actual output is the edited
+// output. Only the output lines of the edited block are used.
+func editOutput(cmd cram.ExecutedCommand) (edited cram.ExecutedCommand,
+	err error) {
+	fp, err := ioutil.TempFile("", "cram-edit-")
+	if err != nil {
+		return
+	}
+	defer os.Remove(fp.Name())
+
+	var doc cram.Document
+	doc.AppendCommand(cmd.CmdLine, cmd.ActualOutput, cmd.ActualExitCode)
+	err = doc.Write(fp)
+	fp.Close()
+	if err != nil {
+		return
+	}
+
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vi"
+	}
+	// The editor can include arguments, so we let the shell split
+	// the command.
+	run := exec.Command("/bin/sh", "-c", editor+` "$1"`, "sh", fp.Name())
+	run.Stdin = os.Stdin
+	run.Stdout = os.Stdout
+	run.Stderr = os.Stderr
+	if err = run.Run(); err != nil {
+		return
+	}
+
+	fp, err = os.Open(fp.Name())
+	if err != nil {
+		return
+	}
+	defer fp.Close()
+	test, err := cram.ParseTest(bufio.NewReader(fp), fp.Name())
+	if err != nil {
+		return
+	}
+	if len(test.Cmds) != 1 {
+		err = errors.New("The edited block must contain exactly one command")
+		return
+	}
+
+	// The edited output is written as it is.
+	command := *cmd.Command
+	command.Unordered = false
+	command.JSON = false
+	edited = cram.ExecutedCommand{
+		Command:        &command,
+		ActualOutput:   test.Cmds[0].ExpectedOutput,
+		ActualExitCode: test.Cmds[0].ExpectedExitCode,
+	}
+	return
+}
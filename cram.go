@@ -9,32 +9,46 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
+	"github.com/mgeisler/cram/pty"
 	"github.com/satori/go.uuid"
 )
 
 const (
 	commandPrefix      = "  $ "
 	continuationPrefix = "  > "
+	inputPrefix        = "  < "
 	outputPrefix       = "  "
+	directivePrefix    = "#cram: "
 
-	reSuffix    = " (re)"
-	globSuffix  = " (glob)"
 	noEolSuffix = " (no-eol)"
 	escSuffix   = " (esc)"
+
+	unorderedSuffix = " (unordered)"
+	jsonSuffix      = " (json)"
 )
 
 type Env map[string]string
 
+// Substitution describes a replacement done on each line of the
+// actual output before it is compared with the expected output. This
+// is used to normalize volatile output such as temporary paths.
+type Substitution struct {
+	Pattern     *regexp.Regexp // Pattern to replace.
+	Replacement string         // Replacement, expanded as in regexp.Expand.
+}
+
 type InvalidTestError struct {
 	Path   string // Path to test file.
-	Lineno int    // Line number of failure
+	Lineno int    // Line number of failure, counting from 1.
 	Msg    string // Error message
 }
 
@@ -43,8 +57,48 @@ func (e *InvalidTestError) Error() string {
 }
 
 type Test struct {
-	Path string    // Path to test file.
-	Cmds []Command // Commands.
+	Path       string      // Path to test file.
+	Cmds       []Command   // Commands.
+	Directives []Directive // Per-file settings.
+}
+
+// Directive is a per-file setting written on a commentary line of the
+// form "#cram: key value".
+type Directive struct {
+	Key    string // Name of the setting.
+	Value  string // Value, with surrounding whitespace removed.
+	Lineno int    // Line number of the directive, counting from 1.
+}
+
+// Options control how a test file is processed. Directives in the
+// test file can add to the options.
+type Options struct {
+	// Substitutions applied to the actual output after the
+	// substitutions of volatile paths.
+	Substitutions []Substitution
+	// How line endings in the test file are parsed.
+	ParseMode ParseMode
+	// Run the commands with their output connected to a
+	// pseudo-terminal.
+	Pty bool
+	// Remove ANSI SGR sequences, such as color codes, from the
+	// actual output before it is compared.
+	StripAnsi bool
+	// Resource limits for the shell running the commands.
+	Limits Limits
+	// Run the shell in a sandbox without network access where only
+	// the working directory is writable. Only supported on Linux.
+	Sandbox bool
+	// Files or directories used by the test, relative to the
+	// directory of the test file. They are part of the Fingerprint.
+	Fixtures []string
+	// Programs on $PATH or files the test depends on. They are part
+	// of the Fingerprint.
+	Depends []string
+	// If positive, only the command at this line (counting from 1)
+	// is checked. The commands before it are run for their side
+	// effects and the commands after it are not run.
+	Line int
 }
 
 type Command struct {
@@ -52,6 +106,25 @@ type Command struct {
 	ExpectedOutput   []string // Expected output lines.
 	ExpectedExitCode int      // Expected exit code.
 	Lineno           int      // Line number of first output line.
+	Unordered        bool     // Output lines can come in any order.
+	JSON             bool     // Output is compared as JSON.
+	Input            []string // Lines written to stdin of the command.
+	cmdLineno        int      // Line number of the command line.
+}
+
+// CmdLineno returns the 0-based line number of the command line.
+func (cmd *Command) CmdLineno() int {
+	return cmd.cmdLineno
+}
+
+// Covers returns true if the line, counting from 1, is one of the
+// command, input, output, or exit code lines of the command.
+func (cmd *Command) Covers(line int) bool {
+	last := cmd.Lineno + len(cmd.ExpectedOutput)
+	if cmd.ExpectedExitCode != 0 {
+		last++
+	}
+	return cmd.CmdLineno() < line && line <= last
 }
 
 type ExecutedCommand struct {
@@ -69,53 +142,91 @@ type ExecutedTest struct {
 	ExecutedCmds []ExecutedCommand // All executed commands.
 	Script       string            // The script passed to the shell.
 	Failures     []ExecutedCommand // Failed commands.
+	Violations   []LimitViolation  // Commands killed by a limit.
+	Attempts     []ExecutedTest    // Earlier failed attempts, if retried.
+	Cached       bool              // Not run since it passed before.
+	Fingerprint  string            // Fingerprint of the inputs, if known.
 }
 
-// matchEntireLine returns true exactly when pattern can be compiled
-// and matches all of line.
-func matchEntireLine(pattern, line string) bool {
-	pattern = "^(?:" + pattern + ")$"
-	matched, err := regexp.MatchString(pattern, line)
-	return err == nil && matched
-}
-
-// globToRegexp translates a glob pattern into the corresponding
-// regular expression. We cannnot simply use filepath.Match since we
-// want "*" to match a sequence of any character instead of stopping
-// at "/" (or "\\" on Windows). Also, filepath.Match has a quirk where
-// there is no escaping on Windows.
-func globToRegexp(pattern string) string {
-	regexpMeta := `\.+*?()|[]{}^$`
-	buf := make([]byte, 2*len(pattern))
-	j := 0
-Loop:
-	for i := 0; i < len(pattern); i++ {
-		switch pattern[i] {
-		case '?':
-			buf[j] = '.'
-		case '*':
-			buf[j], buf[j+1] = '.', '*'
-			j++
-		case '\\':
-			// Skip over the backslash
-			i++
-			if i == len(pattern) {
-				break Loop
+// Failed returns true if a command failed or was killed by a limit.
+func (t *ExecutedTest) Failed() bool {
+	return len(t.Failures) > 0 || len(t.Violations) > 0
+}
+
+// Flaky returns true if the test passed after failed attempts.
+func (t *ExecutedTest) Flaky() bool {
+	return len(t.Attempts) > 0 && !t.Failed()
+}
+
+// matchLine returns true if the actual output line matches the
+// expected output line.
+func matchLine(expected, actual string) bool {
+	// Always accept an exact match, even if the line might end
+	// with (re). This means that such lines need no escaping in
+	// the test file and are quick to match. Lines which are not
+	// valid patterns are rejected by ParseTest, so Patch escapes
+	// them.
+	if actual == expected {
+		return true
+	}
+
+	// The following tests ignore EOLs.
+	actual = DropEol(actual)
+	expected = DropEol(expected)
+
+	// Without a suffix naming a matcher, the lines are not equal by
+	// the check above => we found a change in the output.
+	m, err := compileLine(escapePlaceholders(expected))
+	return err == nil && m != nil && m(actual)
+}
+
+// matchUnordered pairs up expected and actual output lines without
+// regard to their order. The result maps the index of each expected
+// line to the index of the actual line it matched, or -1 if it was
+// not matched. Since a pattern can match several lines, the pairing
+// is found as a maximum bipartite matching using augmenting paths.
+func matchUnordered(expected, actual []string) []int {
+	matches := make([][]int, len(expected))
+	for i, e := range expected {
+		for j, a := range actual {
+			if matchLine(e, a) {
+				matches[i] = append(matches[i], j)
 			}
-			// If we didn't break, add the next character to buf as a
-			// literal.
-			fallthrough
-		default:
-			// Escape character is necessary
-			if strings.IndexByte(regexpMeta, pattern[i]) >= 0 {
-				buf[j] = '\\'
-				j++
+		}
+	}
+
+	pairedWith := make([]int, len(actual))
+	for j := range pairedWith {
+		pairedWith[j] = -1
+	}
+	var augment func(i int, seen []bool) bool
+	augment = func(i int, seen []bool) bool {
+		for _, j := range matches[i] {
+			if seen[j] {
+				continue
+			}
+			seen[j] = true
+			if pairedWith[j] < 0 || augment(pairedWith[j], seen) {
+				pairedWith[j] = i
+				return true
 			}
-			buf[j] = pattern[i]
 		}
-		j++
+		return false
 	}
-	return string(buf[:j])
+	for i := range expected {
+		augment(i, make([]bool, len(actual)))
+	}
+
+	pairs := make([]int, len(expected))
+	for i := range pairs {
+		pairs[i] = -1
+	}
+	for j, i := range pairedWith {
+		if i >= 0 {
+			pairs[i] = j
+		}
+	}
+	return pairs
 }
 
 // failed indicates if the actual exit code or output differed from
@@ -124,53 +235,60 @@ func (cmd *ExecutedCommand) failed() bool {
 	if cmd.ActualExitCode != cmd.ExpectedExitCode {
 		return true
 	}
+	if cmd.JSON {
+		return !matchJSON(cmd.ExpectedOutput, cmd.ActualOutput)
+	}
 	if len(cmd.ActualOutput) != len(cmd.ExpectedOutput) {
 		return true
 	}
-	for i, actual := range cmd.ActualOutput {
-		expected := cmd.ExpectedOutput[i]
-		// Always accept an exact match, even if the line might end
-		// with (re). This means that such lines need no escaping in
-		// the test file and are quick to match.
-		if actual == expected {
-			continue
-		}
-
-		// The following tests ignore EOLs.
-		actual = DropEol(actual)
-		expected = DropEol(expected)
-
-		switch {
-		case strings.HasSuffix(expected, reSuffix):
-			pattern := expected[:len(expected)-len(reSuffix)]
-			if !matchEntireLine(pattern, actual) {
-				return true
-			}
-		case strings.HasSuffix(expected, globSuffix):
-			pattern := expected[:len(expected)-len(globSuffix)]
-			if !matchEntireLine(globToRegexp(pattern), actual) {
-				return true
-			}
-		case strings.HasSuffix(expected, escSuffix):
-			// The same output can be escaped in multiple differnet
-			// ways by the user: both "x (esc)" and "\x78 (esc)" are
-			// ways of saying "x". We normalize the output by
-			// unescaping and then escaping it. This ensures that the
-			// escaped form is the same as what was applied to the
-			// actual output in ParseOutput.
-			expected, err := Unescape(expected)
-			if err != nil || Escape(expected) != actual {
+	if cmd.Unordered {
+		for _, j := range matchUnordered(cmd.ExpectedOutput, cmd.ActualOutput) {
+			if j < 0 {
 				return true
 			}
-		default:
-			// No special suffix, not equal by the check above => we
-			// found a change in the output.
+		}
+		return false
+	}
+	for i, actual := range cmd.ActualOutput {
+		if !matchLine(cmd.ExpectedOutput[i], actual) {
 			return true
 		}
 	}
 	return false
 }
 
+// patchedOutput returns the output lines that should replace the
+// expected output when the command is patched. This is normally the
+// actual output. For unordered commands, we keep the expected lines
+// that matched (in their original order) and add the unmatched
+// actual lines at the end. JSON output is written in a canonical
+// form.
+func (cmd *ExecutedCommand) patchedOutput() []string {
+	if cmd.JSON {
+		if lines, err := formatJSON(cmd.ActualOutput); err == nil {
+			return lines
+		}
+		return cmd.ActualOutput
+	}
+	if !cmd.Unordered {
+		return cmd.ActualOutput
+	}
+	lines := []string{}
+	used := make([]bool, len(cmd.ActualOutput))
+	for i, j := range matchUnordered(cmd.ExpectedOutput, cmd.ActualOutput) {
+		if j >= 0 {
+			lines = append(lines, cmd.ExpectedOutput[i])
+			used[j] = true
+		}
+	}
+	for j, line := range cmd.ActualOutput {
+		if !used[j] {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
 // DropEol removes a final end-of-line from s. It removes both Unix ("\n")
 // and DOS ("\r\n") end-of-line characters.
 func DropEol(s string) string {
@@ -185,6 +303,17 @@ func DropEol(s string) string {
 	return s[:l-drop]
 }
 
+// parseExitCode returns the exit code in an "[n]" line (without
+// EOL). The ok result is false for other lines.
+func parseExitCode(line string) (exitCode int, ok bool) {
+	l := len(line)
+	if l == 0 || line[0] != '[' || line[l-1] != ']' {
+		return
+	}
+	exitCode, err := strconv.Atoi(line[1 : l-1])
+	return exitCode, err == nil
+}
+
 // updateExitCode looks at the last line of output and updates exit
 // code if it is of the form [n]. The exit code line is only required
 // for non-zero exit codes.
@@ -193,15 +322,8 @@ func updateExitCode(cmd *Command) {
 	if lines == 0 {
 		return
 	}
-	line := DropEol(cmd.ExpectedOutput[lines-1])
-
-	l := len(line)
-	if l == 0 || line[0] != '[' || line[l-1] != ']' {
-		return
-	}
-
-	exitCode, err := strconv.Atoi(line[1 : l-1])
-	if err != nil {
+	exitCode, ok := parseExitCode(DropEol(cmd.ExpectedOutput[lines-1]))
+	if !ok {
 		// Not an exit code, just normal output.
 		return
 	}
@@ -209,68 +331,107 @@ func updateExitCode(cmd *Command) {
 	cmd.ExpectedExitCode = exitCode
 }
 
-// Parse splits an input test file into Commands.
-func ParseTest(r io.Reader, path string) (test Test, err error) {
-	const (
-		inCommentary = iota
-		inCommand
-		inOutput
-	)
-
-	test.Path = path
-	reader := bufio.NewReader(r)
-	state := inCommentary
-	lineno := 0
-	line := ""
-	for err == nil {
-		line, err = reader.ReadString('\n')
-		switch {
-		case strings.HasPrefix(line, commandPrefix):
-			if state == inOutput {
-				updateExitCode(&test.Cmds[len(test.Cmds)-1])
+// parseDirective splits a "#cram: key value" line into a Directive.
+func parseDirective(line string, lineno int) Directive {
+	fields := strings.TrimSpace(line[len(directivePrefix):])
+	i := strings.IndexAny(fields, " \t")
+	if i < 0 {
+		return Directive{fields, "", lineno}
+	}
+	return Directive{fields[:i], strings.TrimSpace(fields[i:]), lineno}
+}
+
+// parseSwitch parses the value of a directive which can be turned
+// on or off. A directive without a value turns the setting on.
+func parseSwitch(test Test, d Directive) (bool, error) {
+	switch d.Value {
+	case "", "on":
+		return true, nil
+	case "off":
+		return false, nil
+	}
+	return false, &InvalidTestError{test.Path, d.Lineno,
+		fmt.Sprintf("Invalid value %q for %s directive, expected on or off",
+			d.Value, d.Key)}
+}
+
+// applyDirectives returns a copy of opts updated with the directives
+// in test.
+func applyDirectives(test Test, opts Options) (Options, error) {
+	// Copy the slices so we don't modify the caller's options.
+	opts.Substitutions = append([]Substitution{}, opts.Substitutions...)
+	opts.Fixtures = append([]string{}, opts.Fixtures...)
+	opts.Depends = append([]string{}, opts.Depends...)
+	for _, d := range test.Directives {
+		switch d.Key {
+		case "substitute":
+			sub, err := ParseSubstitution(d.Value)
+			if err != nil {
+				return opts, &InvalidTestError{test.Path, d.Lineno,
+					err.Error()}
 			}
-			line = line[len(commandPrefix):]
-			cmd := Command{
-				CmdLine: line,
-				Lineno:  lineno + 1,
+			opts.Substitutions = append(opts.Substitutions, sub)
+		case "pty":
+			on, err := parseSwitch(test, d)
+			if err != nil {
+				return opts, err
 			}
-			test.Cmds = append(test.Cmds, cmd)
-			state = inCommand
-		case strings.HasPrefix(line, continuationPrefix):
-			if state != inCommand {
-				err = &InvalidTestError{path, lineno,
-					fmt.Sprintf("Continuation line %q has no command", line)}
-				return
+			opts.Pty = on
+		case "limit":
+			if err := opts.Limits.Set(d.Value); err != nil {
+				return opts, &InvalidTestError{test.Path, d.Lineno,
+					err.Error()}
 			}
-			line = line[len(continuationPrefix):]
-			cmd := &test.Cmds[len(test.Cmds)-1]
-			cmd.CmdLine = cmd.CmdLine + line
-			cmd.Lineno++
-		case strings.HasPrefix(line, outputPrefix):
-			if state == inCommentary {
-				err = &InvalidTestError{path, lineno,
-					fmt.Sprintf("Output line %q has no command", line)}
-				return
+		case "fixture":
+			opts.Fixtures = append(opts.Fixtures, strings.Fields(d.Value)...)
+		case "depends":
+			opts.Depends = append(opts.Depends, strings.Fields(d.Value)...)
+		case "sandbox":
+			on, err := parseSwitch(test, d)
+			if err != nil {
+				return opts, err
 			}
-			line = line[len(outputPrefix):]
-			cmd := &test.Cmds[len(test.Cmds)-1]
-			cmd.ExpectedOutput = append(cmd.ExpectedOutput, line)
-			state = inOutput
-		default:
-			if state == inOutput {
-				updateExitCode(&test.Cmds[len(test.Cmds)-1])
+			opts.Sandbox = on
+		case "strip-ansi":
+			on, err := parseSwitch(test, d)
+			if err != nil {
+				return opts, err
 			}
-			state = inCommentary
+			opts.StripAnsi = on
+		default:
+			return opts, &InvalidTestError{test.Path, d.Lineno,
+				fmt.Sprintf("Unknown directive %q", d.Key)}
 		}
-		lineno++
 	}
-	if state == inOutput {
-		updateExitCode(&test.Cmds[len(test.Cmds)-1])
-	}
-	if err == io.EOF {
-		err = nil
+	return opts, nil
+}
+
+// parseAnnotations removes annotations such as (unordered) from the
+// end of the command line and records them in cmd.
+func parseAnnotations(cmd *Command) {
+	for {
+		trimmed := DropEol(cmd.CmdLine)
+		eol := cmd.CmdLine[len(trimmed):]
+		switch {
+		case strings.HasSuffix(trimmed, unorderedSuffix):
+			// The output lines can be matched in any order.
+			trimmed = trimmed[:len(trimmed)-len(unorderedSuffix)]
+			cmd.Unordered = true
+		case strings.HasSuffix(trimmed, jsonSuffix):
+			// The output is compared as a JSON value.
+			trimmed = trimmed[:len(trimmed)-len(jsonSuffix)]
+			cmd.JSON = true
+		default:
+			return
+		}
+		cmd.CmdLine = trimmed + eol
 	}
-	return
+}
+
+// Parse splits an input test file into Commands.
+func ParseTest(r io.Reader, path string) (Test, error) {
+	doc, err := ParseDocument(r, path)
+	return doc.Test(), err
 }
 
 // MakeBanner turns a UUID into a nice banner we can recognize later
@@ -281,11 +442,26 @@ func MakeBanner(u uuid.UUID) string {
 
 // MakeScript produces a script ready to be sent to a shell. The
 // banner should be a random string. It will be inserted in the output
-// together with the exit status of each command.
+// together with the exit status of each command. Commands with input
+// are run with the input in a here-document as their stdin.
 func MakeScript(cmds []Command, banner string) (lines []string) {
 	echo := fmt.Sprintf("echo \"--- CRAM $? %s\"\n", banner)
+	delimiter := "--- CRAM INPUT " + banner
 	for _, cmd := range cmds {
-		lines = append(lines, cmd.CmdLine, echo)
+		if len(cmd.Input) == 0 {
+			// The command line lacks an EOL at the end of a
+			// test file without a final newline.
+			lines = append(lines, withEol(cmd.CmdLine, "\n"), echo)
+			continue
+		}
+		// The braces make the here-document the stdin of the whole
+		// command line, not just the last simple command in it.
+		lines = append(lines, "{\n", withEol(cmd.CmdLine, "\n"),
+			fmt.Sprintf("} << '%s'\n", delimiter))
+		for _, line := range cmd.Input {
+			lines = append(lines, withEol(line, "\n"))
+		}
+		lines = append(lines, delimiter+"\n", echo)
 	}
 	return
 }
@@ -347,16 +523,7 @@ func MakeEnvironment(path string) ([]string, error) {
 // newline in s (if any) is kept unescaped.
 func Escape(s string) string {
 	trimmed := DropEol(s)
-	quoted := strconv.Quote(trimmed)
-	inner := quoted[1 : len(quoted)-1]
-
-	// strconv.Quote changed `"` to `\"` and `\` to `\\` since it
-	// returns a double quoted string. We want to undo this since both
-	// " and \ are printable and need no escaping in our test files.
-	//
-	// Replacing `\"` with `"` is reversable in Unescape since there
-	// can be no lone occurance of `"` in quoted.
-	cleaned := strings.Replace(inner, `\"`, `"`, -1)
+	cleaned := quoteLine(trimmed)
 
 	// We cannot do the same for backslash since we won't be able to
 	// reverse this in Unescape (there will most likely be other
@@ -374,6 +541,21 @@ func Escape(s string) string {
 	return cleaned + escSuffix + s[len(trimmed):]
 }
 
+// quoteLine escapes the characters in s which need escaping in a
+// line marked with "(esc)".
+func quoteLine(s string) string {
+	quoted := strconv.Quote(s)
+	inner := quoted[1 : len(quoted)-1]
+
+	// strconv.Quote changed `"` to `\"` and `\` to `\\` since it
+	// returns a double quoted string. We want to undo this since both
+	// " and \ are printable and need no escaping in our test files.
+	//
+	// Replacing `\"` with `"` is reversable in Unescape since there
+	// can be no lone occurance of `"` in quoted.
+	return strings.Replace(inner, `\"`, `"`, -1)
+}
+
 // Unescape is the inverse of Escape. It decodes escaped characters
 // such as \t, \x01, etc in s if s ends with escSuffix.
 func Unescape(s string) (string, error) {
@@ -391,12 +573,158 @@ func Unescape(s string) (string, error) {
 	return unquoted + s[len(trimmed):], nil
 }
 
+// AnsiSubstitution removes ANSI SGR sequences such as "\x1b[31m",
+// which are used for colors and text styles in terminal output.
+var AnsiSubstitution = Substitution{
+	regexp.MustCompile(`\x1b\[[0-9;]*m`), "",
+}
+
+// PathSubstitution returns a Substitution which replaces path with
+// placeholder. The placeholder is inserted literally. The path must
+// be followed by a "/", the end of the line, or a character which
+// cannot be part of a file name so that a sibling such as
+// /tmp/house is left alone when replacing /tmp/ho.
+func PathSubstitution(path, placeholder string) Substitution {
+	return Substitution{
+		Pattern: regexp.MustCompile(regexp.QuoteMeta(path) +
+			`(/|$|[^\w.-])`),
+		Replacement: strings.Replace(placeholder, "$", "$$", -1) + "${1}",
+	}
+}
+
+// ParseSubstitution parses a rule of the form "s/regexp/replacement/"
+// into a Substitution. Any character can be used instead of "/" and
+// it can be escaped with a backslash. The replacement can refer to
+// submatches as $1 or ${name}.
+func ParseSubstitution(rule string) (sub Substitution, err error) {
+	if len(rule) < 2 || rule[0] != 's' {
+		err = fmt.Errorf("Substitution %q must be of the form s/regexp/replacement/", rule)
+		return
+	}
+	delim := rule[1]
+	parts := []string{}
+	part := []byte{}
+	for i := 2; i < len(rule); i++ {
+		switch {
+		case rule[i] == '\\' && i+1 < len(rule) && rule[i+1] == delim:
+			part = append(part, delim)
+			i++
+		case rule[i] == delim:
+			parts = append(parts, string(part))
+			part = []byte{}
+		default:
+			part = append(part, rule[i])
+		}
+	}
+	if len(parts) != 2 || len(part) != 0 {
+		err = fmt.Errorf("Substitution %q must be of the form s/regexp/replacement/", rule)
+		return
+	}
+	pattern, err := regexp.Compile(parts[0])
+	if err != nil {
+		err = fmt.Errorf("Substitution %q has invalid pattern: %s", rule, err)
+		return
+	}
+	sub = Substitution{pattern, parts[1]}
+	return
+}
+
+// byPathLength sorts paths with the longest paths first.
+type byPathLength [][2]string
+
+func (p byPathLength) Len() int           { return len(p) }
+func (p byPathLength) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p byPathLength) Less(i, j int) bool { return len(p[i][0]) > len(p[j][0]) }
+
+// MakePathSubstitutions returns the substitutions used to hide the
+// volatile paths involved in executing a test: the working directory
+// becomes $TESTTMP, the temporary directory holding all working
+// directories becomes $CRAMTMP, the directory of the test file
+// becomes $TESTDIR, and the home directory becomes $HOME. Longer
+// paths are substituted first so that $TESTTMP wins over $CRAMTMP.
+func MakePathSubstitutions(tempdir, workdir, path string) (
+	subs []Substitution, err error) {
+	testdir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return
+	}
+	candidates := [][2]string{
+		{workdir, "$TESTTMP"},
+		{tempdir, "$CRAMTMP"},
+		{testdir, "$TESTDIR"},
+		{os.Getenv("HOME"), "$HOME"},
+	}
+
+	paths := [][2]string{}
+	for _, candidate := range candidates {
+		abs, err := filepath.Abs(candidate[0])
+		// Replacing the root directory (or nothing at all) would
+		// mangle any path in the output.
+		if candidate[0] == "" || err != nil || abs == filepath.Dir(abs) {
+			continue
+		}
+		paths = append(paths, [2]string{abs, candidate[1]})
+		// The shell reports the physical path of the working
+		// directory, so we also replace the path with symlinks
+		// resolved.
+		if real, err := filepath.EvalSymlinks(abs); err == nil && real != abs {
+			paths = append(paths, [2]string{real, candidate[1]})
+		}
+	}
+	sort.Stable(byPathLength(paths))
+
+	for _, p := range paths {
+		subs = append(subs, PathSubstitution(p[0], p[1]))
+	}
+	return
+}
+
+// unescapedPlaceholder matches a path placeholder which is not
+// preceded by a backslash.
+var unescapedPlaceholder = regexp.MustCompile(
+	`(^|[^\\])\$(TESTTMP|CRAMTMP|TESTDIR|HOME)`)
+
+// escapePlaceholders escapes the path placeholders in an expected
+// output line ending with " (re)" before it is matched. The "$" of a
+// placeholder would otherwise be an anchor and the pattern could
+// never match the placeholder in the actual output. Other lines are
+// returned unchanged.
+func escapePlaceholders(line string) string {
+	if !strings.HasSuffix(DropEol(line), " (re)") {
+		return line
+	}
+	// Placeholders next to each other need a second pass since
+	// the matches cannot overlap.
+	for {
+		escaped := unescapedPlaceholder.ReplaceAllString(line, `${1}\$$${2}`)
+		if escaped == line {
+			return line
+		}
+		line = escaped
+	}
+}
+
+// Substitute applies subs in order to line. A final EOL in line is
+// kept out of reach of the substitutions.
+func Substitute(line string, subs []Substitution) string {
+	if len(subs) == 0 {
+		return line
+	}
+	trimmed := DropEol(line)
+	result := trimmed
+	for _, sub := range subs {
+		result = sub.Pattern.ReplaceAllString(result, sub.Replacement)
+	}
+	return result + line[len(trimmed):]
+}
+
 // ParseOutput finds the actual output and exit codes for a slice of
 // commands. The result is a slice of executed commands. The actual
 // output is normalized, meaning that a missing final EOL in the
-// output is represented as noEolSuffix.
-func ParseOutput(cmds []Command, output []byte, banner string) (
-	executed []ExecutedCommand, err error) {
+// output is represented as noEolSuffix and that the substitutions
+// in subs have been applied to each line.
+func ParseOutput(cmds []Command, output []byte, banner string,
+	subs []Substitution) (executed []ExecutedCommand, err error) {
 	r := bytes.NewReader(output)
 	reader := bufio.NewReader(r)
 
@@ -415,7 +743,7 @@ func ParseOutput(cmds []Command, output []byte, banner string) (
 
 			prefix := line[:lastSpace-len("--- CRAM")]
 			if len(prefix) > 0 {
-				line := prefix + noEolSuffix + "\n"
+				line := Substitute(prefix, subs) + noEolSuffix + "\n"
 				actualOutput = append(actualOutput, Escape(line))
 			}
 
@@ -434,6 +762,7 @@ func ParseOutput(cmds []Command, output []byte, banner string) (
 			actualOutput = nil
 			i++
 		} else {
+			line = Substitute(line, subs)
 			actualOutput = append(actualOutput, Escape(line))
 		}
 	}
@@ -443,14 +772,173 @@ func ParseOutput(cmds []Command, output []byte, banner string) (
 	return
 }
 
-// Execute a script in the specified working directory.
-func ExecuteScript(workdir string, env []string, lines []string) ([]byte, error) {
-	script := strings.Join(lines, "")
-	cmd := exec.Command("/bin/sh", "-")
+// shellCommand returns a command which runs a shell reading its
+// script from file descriptor 3. This leaves stdin free for the
+// commands in the script, so a command reading stdin cannot consume
+// the rest of the script. The stdin of the shell is /dev/null unless
+// the caller sets it.
+func shellCommand(workdir string, env []string) *exec.Cmd {
+	cmd := exec.Command("/bin/sh", "/dev/fd/3")
 	cmd.Dir = workdir
 	cmd.Env = env
-	cmd.Stdin = strings.NewReader(script)
-	return cmd.CombinedOutput()
+	return cmd
+}
+
+// prepareShell returns a command from shellCommand which runs in a
+// sandbox if requested in opts. The sandbox is nil otherwise and must
+// be closed after use.
+func prepareShell(workdir string, env []string, opts Options) (
+	cmd *exec.Cmd, sb *sandbox, err error) {
+	cmd = shellCommand(workdir, env)
+	if opts.Sandbox {
+		sb, err = newSandbox(cmd)
+	}
+	return
+}
+
+// startScript starts cmd from prepareShell, applies the limits, and
+// writes the script lines to it in the background.
+func startScript(cmd *exec.Cmd, sb *sandbox, lines []string,
+	limits Limits) error {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	cmd.ExtraFiles = append([]*os.File{r}, cmd.ExtraFiles...)
+	err = cmd.Start()
+	r.Close()
+	if err != nil {
+		w.Close()
+		if sb != nil {
+			err = sb.startError(err)
+		}
+		return err
+	}
+	// The shell waits for the script, so no commands have been run
+	// before the limits are in place.
+	if err = setLimits(cmd.Process.Pid, limits); err != nil {
+		err = fmt.Errorf("Could not set resource limits: %s", err)
+	} else if sb != nil {
+		err = sb.ready()
+	}
+	if err != nil {
+		w.Close()
+		cmd.Wait()
+		return err
+	}
+
+	script := "exec 3<&-\n" + strings.Join(lines, "")
+	if sb != nil {
+		script = sb.scriptPrefix() + script
+	}
+	go func() {
+		// The shell has the script open on another file descriptor,
+		// so closing descriptor 3 hides the script from the
+		// commands. The write fails if a command makes the shell
+		// exit early, which is fine.
+		io.WriteString(w, script)
+		w.Close()
+	}()
+	return nil
+}
+
+// Execute a script in the specified working directory.
+func ExecuteScript(workdir string, env []string, lines []string) ([]byte, error) {
+	return executeScript(workdir, env, lines, Options{})
+}
+
+// executeScript is like ExecuteScript, but runs the shell with the
+// resource limits and sandbox given in opts.
+func executeScript(workdir string, env []string, lines []string,
+	opts Options) ([]byte, error) {
+	cmd, sb, err := prepareShell(workdir, env, opts)
+	if err != nil {
+		return nil, err
+	}
+	if sb != nil {
+		defer sb.close()
+	}
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err = startScript(cmd, sb, lines, opts.Limits); err != nil {
+		return nil, err
+	}
+	err = cmd.Wait()
+	return output.Bytes(), err
+}
+
+// ExecuteScriptPty is like ExecuteScript, but the output of the
+// script is written to a pseudo-terminal. Programs will thus behave
+// like they do when run interactively. The terminal size is taken
+// from the COLUMNS and LINES variables in env. The terminal does not
+// turn "\n" into "\r\n", so the output can be parsed with
+// ParseOutput like normal.
+func ExecuteScriptPty(workdir string, env []string, lines []string) (
+	[]byte, error) {
+	return executeScriptPty(workdir, env, lines, Options{})
+}
+
+// executeScriptPty is like ExecuteScriptPty, but runs the shell with
+// the resource limits and sandbox given in opts.
+func executeScriptPty(workdir string, env []string, lines []string,
+	opts Options) ([]byte, error) {
+	master, slave, err := pty.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer master.Close()
+
+	vars := parseEnviron(env)
+	cols, err := strconv.Atoi(vars["COLUMNS"])
+	if err != nil {
+		cols = 80
+	}
+	rows, err := strconv.Atoi(vars["LINES"])
+	if err != nil {
+		rows = 24
+	}
+	if err = pty.SetSize(slave, rows, cols); err != nil {
+		slave.Close()
+		return nil, err
+	}
+
+	cmd, sb, err := prepareShell(workdir, env, opts)
+	if err != nil {
+		slave.Close()
+		return nil, err
+	}
+	if sb != nil {
+		defer sb.close()
+	}
+	pty.Setup(cmd, slave)
+	err = startScript(cmd, sb, lines, opts.Limits)
+	// Only the shell should keep the slave open. Reading from the
+	// master then ends when the shell and its children exit.
+	slave.Close()
+	if err != nil {
+		return nil, err
+	}
+	output, err := pty.ReadAll(master)
+	if e := cmd.Wait(); err == nil {
+		err = e
+	}
+	return output, err
+}
+
+// CheckSandbox runs an empty script in a sandbox and returns the
+// error, if any. This tells if the system supports the sandbox
+// before any tests are run.
+func CheckSandbox() error {
+	workdir, err := ioutil.TempDir("", "cram-check-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(workdir)
+	_, err = executeScript(workdir, os.Environ(), nil,
+		Options{Sandbox: true})
+	return err
 }
 
 func filterFailures(executed []ExecutedCommand) (failures []ExecutedCommand) {
@@ -464,83 +952,150 @@ func filterFailures(executed []ExecutedCommand) (failures []ExecutedCommand) {
 
 // Patch takes an ExecutedTest, a slice ExecutedCommands and returns
 // the patched output where ActualOutput from each ExecutedCommand
-// replaces the ExpectedOutput.
-func Patch(r io.Reader, w io.Writer, cmds []ExecutedCommand) (err error) {
-	reader := bufio.NewReader(r)
-	writer := bufio.NewWriter(w)
-
-	lines := []string{}
-	line := ""
-	for err == nil {
-		line, err = reader.ReadString('\n')
-		lines = append(lines, line)
-	}
-	if err == io.EOF {
-		err = nil
+// replaces the ExpectedOutput. Everything else in the test file is
+// written unchanged.
+func Patch(r io.Reader, w io.Writer, cmds []ExecutedCommand) error {
+	doc, err := ParseDocument(r, "")
+	if err != nil {
+		return err
 	}
 
-	output := []string{}
-	lastLineno := 0
-
 	for _, cmd := range cmds {
-		pre := lines[lastLineno:cmd.Lineno]
-		output = append(output, pre...)
-		for _, outputLine := range cmd.ActualOutput {
-			output = append(output, "  "+outputLine)
+		block := doc.commandBlock(cmd.Lineno)
+		if block == nil {
+			return fmt.Errorf("No command with output on line %d",
+				cmd.Lineno+1)
 		}
-		lastLine := output[len(output)-1]
-		if lastLine[len(lastLine)-1] != '\n' {
-			output[len(output)-1] = lastLine + noEolSuffix + "\n"
+		output := cmd.patchedOutput()
+		if len(output) > 0 || cmd.ActualExitCode != 0 {
+			block.ensureEol(doc.eol())
 		}
-		lastLineno = cmd.Lineno + len(cmd.ExpectedOutput)
-
+		block.setOutput(output, doc.eol())
 		if cmd.ActualExitCode != cmd.ExpectedExitCode {
-			// Add a line with the actual exit code, but only if it is
-			// non-zero since [0] is implied.
-			if cmd.ActualExitCode != 0 {
-				line := fmt.Sprintf("  [%d]\n", cmd.ActualExitCode)
-				output = append(output, line)
-			}
+			block.setExitCode(cmd.ActualExitCode, doc.eol())
+		}
+	}
+	return doc.Write(w)
+}
 
-			// Figure out if we should skip a line in the input file.
-			// We should only skip a line if we are overwriting the
-			// expected exit code -- we should not skip a line if we
-			// merely added the actual exit code.
-			if lastLineno < len(lines) {
-				lastLine := lines[lastLineno]
-				line := fmt.Sprintf("  [%d]", cmd.ExpectedExitCode)
-				// Extra whitespace on the exit code line is okay.
-				if strings.HasPrefix(lastLine, line) {
-					lastLineno++
-				}
-			}
+// PatchFile patches the test file at path in place with the actual
+// output of cmds. The patched file is written to a temporary file in
+// the same directory, which is then renamed over the original. The
+// original file is thus either left untouched or completely replaced.
+// The file mode is preserved. If backup is set, the original content
+// is saved with an ".orig" extension.
+func PatchFile(path string, cmds []ExecutedCommand, backup bool) (err error) {
+	// Patch the file a symlink points to instead of replacing the
+	// symlink itself.
+	path, err = filepath.EvalSymlinks(path)
+	if err != nil {
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+
+	dir, base := filepath.Split(path)
+	tmp, err := ioutil.TempFile(dir, "."+base+".patched-")
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if err = Patch(bytes.NewReader(data), tmp, cmds); err != nil {
+		return
+	}
+	if err = tmp.Chmod(info.Mode().Perm()); err != nil {
+		return
+	}
+	if err = tmp.Sync(); err != nil {
+		return
+	}
+	if err = tmp.Close(); err != nil {
+		return
+	}
+
+	if backup {
+		err = ioutil.WriteFile(path+".orig", data, info.Mode().Perm())
+		if err != nil {
+			return
 		}
 	}
-	post := lines[lastLineno:]
-	output = append(output, post...)
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return
+	}
 
-	for _, line := range output {
-		_, err = writer.WriteString(line)
+	// Make the rename durable. Not all platforms support syncing a
+	// directory, so errors are ignored.
+	if d, e := os.Open(filepath.Dir(path)); e == nil {
+		d.Sync()
+		d.Close()
 	}
-	err = writer.Flush()
 	return
 }
 
+// load parses the test file at path and applies its directives to
+// opts.
+func load(path string, opts Options) (Test, Options, error) {
+	fp, err := os.Open(path)
+	if err != nil {
+		return Test{Path: path}, opts, err
+	}
+	defer fp.Close()
+	doc, err := ParseDocumentMode(fp, path, opts.ParseMode)
+	test := doc.Test()
+	if err != nil {
+		return test, opts, err
+	}
+	opts, err = applyDirectives(test, opts)
+	return test, opts, err
+}
+
+// Validate parses a .t file and checks its directives without
+// executing any commands. Invalid output patterns, escapes, and
+// directives are reported as an InvalidTestError.
+func Validate(path string, opts Options) (Test, error) {
+	test, _, err := load(path, opts)
+	if err != nil {
+		return Test{Path: path}, err
+	}
+	return test, nil
+}
+
 // Process parses a .t file, executes the test commands and compares
 // the actual output to the expected output. The idx passed is used to
 // make the working directory unique inside tempdir and must be
 // different for each test file.
-func Process(tempdir, path string, idx int) (result ExecutedTest, err error) {
+func Process(tempdir, path string, idx int, opts Options) (
+	result ExecutedTest, err error) {
 	// Make sure Path is set, even if we fail later.
 	result.Path = path
-	fp, err := os.Open(path)
+	test, opts, err := load(path, opts)
 	if err != nil {
 		return
 	}
-	defer fp.Close()
-	test, err := ParseTest(fp, path)
-	if err != nil {
-		return
+	selected := -1
+	if opts.Line > 0 {
+		for i := range test.Cmds {
+			if test.Cmds[i].Covers(opts.Line) {
+				selected = i
+			}
+		}
+		if selected < 0 {
+			err = &InvalidTestError{path, opts.Line, "No command on this line"}
+			return
+		}
+		test.Cmds = test.Cmds[:selected+1]
 	}
 
 	// Create unique base inside the tempdir
@@ -561,18 +1116,64 @@ func Process(tempdir, path string, idx int) (result ExecutedTest, err error) {
 		return
 	}
 
-	output, err := ExecuteScript(workdir, env, lines)
+	subs, err := MakePathSubstitutions(tempdir, workdir, path)
 	if err != nil {
 		return
 	}
+	// The expected output can contain the paths literally or the
+	// placeholders. We normalize it to use the placeholders.
+	for i := range test.Cmds {
+		for j, line := range test.Cmds[i].ExpectedOutput {
+			test.Cmds[i].ExpectedOutput[j] = Substitute(line, subs)
+		}
+	}
 
-	executed, err := ParseOutput(test.Cmds, output, banner)
+	execute := executeScript
+	if opts.Pty {
+		execute = executeScriptPty
+	}
+	output, err := execute(workdir, env, lines, opts)
+	// The shell itself is killed by a limit when it runs a loop or
+	// another builtin exceeding the limit. The output of the commands
+	// before is kept.
+	killed := shellViolation(err, opts.Limits)
+	if killed != 0 {
+		err = nil
+	}
 	if err != nil {
 		return
 	}
 
+	if opts.StripAnsi {
+		// Strip the sequences first so they cannot hide paths from
+		// the other substitutions.
+		subs = append([]Substitution{AnsiSubstitution}, subs...)
+	}
+	subs = append(subs, opts.Substitutions...)
+	executed, err := ParseOutput(test.Cmds, output, banner, subs)
+	if err != nil {
+		return
+	}
+	if killed != 0 && len(executed) < len(test.Cmds) {
+		executed = append(executed, ExecutedCommand{
+			Command:        &test.Cmds[len(executed)],
+			ActualExitCode: killed,
+		})
+	}
+
 	failures := filterFailures(executed)
+	if selected >= 0 {
+		// Only the selected command is checked.
+		var checked []ExecutedCommand
+		for _, cmd := range failures {
+			if cmd.Command == &test.Cmds[selected] {
+				checked = append(checked, cmd)
+			}
+		}
+		failures = checked
+	}
+	violations, failures := findViolations(failures, opts.Limits)
 	result = ExecutedTest{test, executed, strings.Join(lines, ""),
-		failures}
+		failures, violations, nil, false, ""}
 	return
 }
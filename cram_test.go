@@ -6,6 +6,10 @@ package cram
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 	"testing"
@@ -176,6 +180,43 @@ func TestExecutedCommandFailed(t *testing.T) {
 	}
 }
 
+func TestExecutedCommandFailedUnordered(t *testing.T) {
+	cmd := Command{
+		CmdLine:        "ls",
+		ExpectedOutput: []string{"a* (glob)\n", "ab\n", "c\n"},
+		Unordered:      true,
+	}
+
+	var tests = []struct {
+		actual   []string
+		expected bool
+	}{
+		{[]string{"ab\n", "ac\n", "c\n"}, false},
+		{[]string{"c\n", "ab\n", "ac\n"}, false},
+		{[]string{"ab\n", "ab\n", "c\n"}, false},
+		{[]string{"ac\n", "ad\n", "c\n"}, true},
+		{[]string{"ab\n", "c\n"}, true},
+		{[]string{"ab\n", "c\n", "c\n"}, true},
+	}
+
+	for _, test := range tests {
+		executed := ExecutedCommand{&cmd, test.actual, 0}
+		assert.Equal(t, test.expected, executed.failed(),
+			fmt.Sprintf("output: %q", test.actual))
+	}
+}
+
+func TestPatchedOutputUnordered(t *testing.T) {
+	cmd := Command{
+		CmdLine:        "ls",
+		ExpectedOutput: []string{"b\n", "a* (glob)\n", "gone\n"},
+		Unordered:      true,
+	}
+	executed := ExecutedCommand{&cmd, []string{"new\n", "ax\n", "b\n"}, 0}
+	assert.Equal(t, []string{"b\n", "a* (glob)\n", "new\n"},
+		executed.patchedOutput())
+}
+
 func TestParseEmpty(t *testing.T) {
 	buf := strings.NewReader("")
 	test, err := ParseTest(buf, "<string>")
@@ -189,11 +230,24 @@ func TestParseOutputOnly(t *testing.T) {
 	buf := strings.NewReader("\n\n  \n")
 	test, err := ParseTest(buf, "<string>")
 
-	assert.EqualError(t, err, `<string>:2: Output line "  \n" has no command`)
+	assert.EqualError(t, err, `<string>:3: Output line "  \n" has no command`)
 	assert.Equal(t, test.Path, "<string>")
 	assert.Len(t, test.Cmds, 0)
 }
 
+func TestParseInvalidPattern(t *testing.T) {
+	buf := strings.NewReader("  $ echo foo\n  fo(o (re)\n")
+	_, err := ParseTest(buf, "<string>")
+
+	assert.EqualError(t, err, `<string>:2: Invalid output line "fo(o (re)\n": `+
+		"error parsing regexp: missing closing ): `fo(o`")
+
+	buf = strings.NewReader("  $ echo foo\n  foo\n  \\q (esc)\n")
+	_, err = ParseTest(buf, "<string>")
+	assert.EqualError(t, err, `<string>:3: Invalid output line "\\q (esc)\n": `+
+		"invalid escape sequence")
+}
+
 func TestParseCommentaryOnly(t *testing.T) {
 	buf := strings.NewReader("This file only has\nsome commentary.\n")
 	test, err := ParseTest(buf, "<string>")
@@ -213,8 +267,10 @@ func TestParseNoOutput(t *testing.T) {
 	cmds := test.Cmds
 	assert.NoError(err)
 	if assert.Len(cmds, 2) {
-		assert.Equal(Command{"touch foo\n", nil, 0, 2}, cmds[0])
-		assert.Equal(Command{"touch bar\n", nil, 0, 3}, cmds[1])
+		assert.Equal(Command{CmdLine: "touch foo\n", Lineno: 2,
+			cmdLineno: 1}, cmds[0])
+		assert.Equal(Command{CmdLine: "touch bar\n", Lineno: 3,
+			cmdLineno: 2}, cmds[1])
 	}
 }
 
@@ -233,14 +289,16 @@ func TestParseCommands(t *testing.T) {
 	cmds := test.Cmds
 	if assert.Len(cmds, 2) {
 		assert.Equal(Command{
-			"echo \"hello\\nworld\"\n",
-			[]string{"hello\n", "world\n"},
-			0, 2,
+			CmdLine:        "echo \"hello\\nworld\"\n",
+			ExpectedOutput: []string{"hello\n", "world\n"},
+			Lineno:         2,
+			cmdLineno:      1,
 		}, cmds[0])
 		assert.Equal(Command{
-			"echo goodbye\n",
-			[]string{"goodbye\n"},
-			0, 5,
+			CmdLine:        "echo goodbye\n",
+			ExpectedOutput: []string{"goodbye\n"},
+			Lineno:         5,
+			cmdLineno:      4,
 		}, cmds[1])
 	}
 }
@@ -274,20 +332,206 @@ Mixture of commands and output:
 	cmds := test.Cmds
 	if assert.Len(cmds, 5) {
 		assert.Equal(Command{
-			"false\n", []string{}, 1, 4},
-			cmds[0])
+			CmdLine:          "false\n",
+			ExpectedOutput:   []string{},
+			ExpectedExitCode: 1,
+			Lineno:           4,
+			cmdLineno:        3,
+		}, cmds[0])
 		assert.Equal(Command{
-			"echo hello; false\n", []string{"hello\n"}, 1, 9},
-			cmds[1])
+			CmdLine:          "echo hello; false\n",
+			ExpectedOutput:   []string{"hello\n"},
+			ExpectedExitCode: 1,
+			Lineno:           9,
+			cmdLineno:        8,
+		}, cmds[1])
+		assert.Equal(Command{
+			CmdLine:          "false\n",
+			ExpectedOutput:   []string{},
+			ExpectedExitCode: 1,
+			Lineno:           15,
+			cmdLineno:        14,
+		}, cmds[2])
+		assert.Equal(Command{
+			CmdLine:   "true\n",
+			Lineno:    17,
+			cmdLineno: 16,
+		}, cmds[3])
+		assert.Equal(Command{
+			CmdLine:          "echo hello; false\n",
+			ExpectedOutput:   []string{"hello\n"},
+			ExpectedExitCode: 1,
+			Lineno:           18,
+			cmdLineno:        17,
+		}, cmds[4])
+	}
+}
+
+func TestCommandCovers(t *testing.T) {
+	input := "Intro\n  $ echo \\\n  > foo\n  foo\n  [1]\n  $ cat\n  < x\n"
+	parsed, err := ParseTest(strings.NewReader(input), "<string>")
+	if !assert.NoError(t, err) || !assert.Len(t, parsed.Cmds, 2) {
+		return
+	}
+
+	var tests = []struct {
+		line     int
+		expected []bool
+	}{
+		{1, []bool{false, false}},
+		{2, []bool{true, false}},
+		{3, []bool{true, false}},
+		{5, []bool{true, false}},
+		{6, []bool{false, true}},
+		{7, []bool{false, true}},
+		{8, []bool{false, false}},
+	}
+
+	for _, test := range tests {
+		for i, cmd := range parsed.Cmds {
+			msg := fmt.Sprintf("Cmds[%d].Covers(%d)", i, test.line)
+			assert.Equal(t, test.expected[i], cmd.Covers(test.line), msg)
+		}
+	}
+
+	// The last command line has no EOL.
+	input = "Intro\n  $ true\n  $ echo \\\n  > foo"
+	parsed, err = ParseTest(strings.NewReader(input), "<string>")
+	if assert.NoError(t, err) && assert.Len(t, parsed.Cmds, 2) {
+		assert.Equal(t, 2, parsed.Cmds[1].CmdLineno())
+		assert.False(t, parsed.Cmds[1].Covers(2))
+		assert.True(t, parsed.Cmds[1].Covers(3))
+		assert.True(t, parsed.Cmds[1].Covers(4))
+	}
+}
+
+func TestParseAnnotations(t *testing.T) {
+	assert := assert.New(t)
+	buf := strings.NewReader(`  $ ls (unordered)
+  foo
+  $ echo '(unordered)'
+  $ cat data.json (json)
+  {}
+`)
+	test, err := ParseTest(buf, "<string>")
+	assert.NoError(err)
+
+	cmds := test.Cmds
+	if assert.Len(cmds, 3) {
 		assert.Equal(Command{
-			"false\n", []string{}, 1, 15},
-			cmds[2])
+			CmdLine:        "ls\n",
+			ExpectedOutput: []string{"foo\n"},
+			Lineno:         1,
+			cmdLineno:      0,
+			Unordered:      true,
+		}, cmds[0])
 		assert.Equal(Command{
-			"true\n", nil, 0, 17},
-			cmds[3])
+			CmdLine:   "echo '(unordered)'\n",
+			Lineno:    3,
+			cmdLineno: 2,
+		}, cmds[1])
 		assert.Equal(Command{
-			"echo hello; false\n", []string{"hello\n"}, 1, 18},
-			cmds[4])
+			CmdLine:        "cat data.json\n",
+			ExpectedOutput: []string{"{}\n"},
+			Lineno:         4,
+			cmdLineno:      3,
+			JSON:           true,
+		}, cmds[2])
+	}
+}
+
+func TestParseDirectives(t *testing.T) {
+	assert := assert.New(t)
+	buf := strings.NewReader(`#cram: substitute s/a/b/
+Some commentary
+#cram:   pty
+  $ true
+  #cram: not a directive since it is output
+`)
+	test, err := ParseTest(buf, "<string>")
+	assert.NoError(err)
+	assert.Equal([]Directive{
+		{"substitute", "s/a/b/", 1},
+		{"pty", "", 3},
+	}, test.Directives)
+}
+
+func TestExecutedTestFlaky(t *testing.T) {
+	failure := ExecutedCommand{Command: &Command{}, ActualExitCode: 1}
+	violation := LimitViolation{failure, "cpu"}
+	failed := ExecutedTest{Failures: []ExecutedCommand{failure}}
+	var tests = []struct {
+		test   ExecutedTest
+		failed bool
+		flaky  bool
+	}{
+		{ExecutedTest{}, false, false},
+		{failed, true, false},
+		{ExecutedTest{Violations: []LimitViolation{violation}}, true, false},
+		{ExecutedTest{Attempts: []ExecutedTest{failed}}, false, true},
+		{ExecutedTest{Failures: failed.Failures,
+			Attempts: []ExecutedTest{failed}}, true, false},
+	}
+
+	for i, test := range tests {
+		msg := fmt.Sprintf("test %d", i)
+		assert.Equal(t, test.failed, test.test.Failed(), msg)
+		assert.Equal(t, test.flaky, test.test.Flaky(), msg)
+	}
+}
+
+func TestApplyDirectives(t *testing.T) {
+	var tests = []struct {
+		value string
+		pty   bool
+	}{
+		{"", true},
+		{"on", true},
+		{"off", false},
+	}
+
+	for _, test := range tests {
+		directives := []Directive{{"pty", test.value, 1}}
+		opts, err := applyDirectives(Test{Directives: directives},
+			Options{Pty: !test.pty})
+		msg := fmt.Sprintf("#cram: pty %s", test.value)
+		assert.NoError(t, err, msg)
+		assert.Equal(t, test.pty, opts.Pty, msg)
+	}
+
+	directives := []Directive{{"pty", "maybe", 3}}
+	_, err := applyDirectives(Test{Path: "<string>", Directives: directives},
+		Options{})
+	assert.EqualError(t, err, `<string>:3: Invalid value "maybe" for `+
+		"pty directive, expected on or off")
+}
+
+func TestParseSubstitution(t *testing.T) {
+	var tests = []struct {
+		rule        string
+		pattern     string
+		replacement string
+	}{
+		{`s/a/b/`, `a`, `b`},
+		{`s///`, ``, ``},
+		{`s|/tmp/|$TMP/|`, `/tmp/`, `$TMP/`},
+		{`s/a\/b/c\/d/`, `a/b`, `c/d`},
+		{`s/(\d+) ms/$1/`, `(\d+) ms`, `$1`},
+	}
+
+	for _, test := range tests {
+		sub, err := ParseSubstitution(test.rule)
+		if assert.NoError(t, err) {
+			assert.Equal(t, test.pattern, sub.Pattern.String())
+			assert.Equal(t, test.replacement, sub.Replacement)
+		}
+	}
+}
+
+func TestParseSubstitutionError(t *testing.T) {
+	for _, rule := range []string{"", "s", "s/a/b", "s/a/b/c", "x/a/b/", "s/(/b/"} {
+		_, err := ParseSubstitution(rule)
+		assert.Error(t, err, fmt.Sprintf("ParseSubstitution(%#v)", rule))
 	}
 }
 
@@ -303,19 +547,34 @@ func TestMakeScript(t *testing.T) {
 	u, err := uuid.FromString("12345678-abcd-1234-abcd-123412345678")
 	assert.NoError(t, err)
 	cmds := []Command{
-		{"ls", nil, 0, 0},
-		{"touch foo.txt", nil, 0, 0},
+		{CmdLine: "ls\n"},
+		{CmdLine: "touch foo.txt"},
 	}
 	lines := MakeScript(cmds, MakeBanner(u))
 	banner := "echo \"--- CRAM $? 12345678-abcd-1234-abcd-123412345678 ---\"\n"
 	if assert.Len(t, lines, 4) {
-		assert.Equal(t, "ls", lines[0])
+		assert.Equal(t, "ls\n", lines[0])
 		assert.Equal(t, banner, lines[1])
-		assert.Equal(t, "touch foo.txt", lines[2])
+		// A missing EOL is added.
+		assert.Equal(t, "touch foo.txt\n", lines[2])
 		assert.Equal(t, banner, lines[3])
 	}
 }
 
+func TestMakeScriptInput(t *testing.T) {
+	u, err := uuid.FromString("12345678-abcd-1234-abcd-123412345678")
+	assert.NoError(t, err)
+	cmds := []Command{
+		{CmdLine: "cat | sort\n", Input: []string{"b\n", "a"}},
+	}
+	script := strings.Join(MakeScript(cmds, MakeBanner(u)), "")
+	delimiter := "--- CRAM INPUT 12345678-abcd-1234-abcd-123412345678 ---"
+	assert.Equal(t, "{\ncat | sort\n} << '"+delimiter+"'\nb\na\n"+
+		delimiter+"\n"+
+		"echo \"--- CRAM $? 12345678-abcd-1234-abcd-123412345678 ---\"\n",
+		script)
+}
+
 func TestParseEnviron(t *testing.T) {
 	var tests = []struct {
 		input    []string
@@ -373,15 +632,15 @@ func TestMakeEnvironment(t *testing.T) {
 
 func TestParseOutputEmpty(t *testing.T) {
 	cmds := []Command{
-		{"touch foo", nil, 0, 0},
-		{"touch bar", nil, 0, 0},
+		{CmdLine: "touch foo"},
+		{CmdLine: "touch bar"},
 	}
 	banner := "12345678-abcd-1234-abcd-123412345678 ---"
 	output := []byte(`--- CRAM 0 12345678-abcd-1234-abcd-123412345678 ---
 --- CRAM 1 12345678-abcd-1234-abcd-123412345678 ---
 `)
 
-	executed, err := ParseOutput(cmds, output, banner)
+	executed, err := ParseOutput(cmds, output, banner, nil)
 	assert.NoError(t, err)
 	if assert.Len(t, executed, 2) {
 		assert.Len(t, executed[0].ActualOutput, 0)
@@ -393,8 +652,8 @@ func TestParseOutputEmpty(t *testing.T) {
 
 func TestParseOutput(t *testing.T) {
 	cmds := []Command{
-		{"echo foo", []string{"foo"}, 0, 0},
-		{"echo bar", []string{"bar"}, 0, 0},
+		{CmdLine: "echo foo", ExpectedOutput: []string{"foo"}},
+		{CmdLine: "echo bar", ExpectedOutput: []string{"bar"}},
 	}
 	banner := "12345678-abcd-1234-abcd-123412345678 ---"
 	output := []byte(`foo
@@ -403,7 +662,7 @@ bar
 --- CRAM 1 12345678-abcd-1234-abcd-123412345678 ---
 `)
 
-	executed, err := ParseOutput(cmds, output, banner)
+	executed, err := ParseOutput(cmds, output, banner, nil)
 	assert.NoError(t, err)
 	if assert.Len(t, executed, 2) {
 		assert.Equal(t, []string{"foo\n"}, executed[0].ActualOutput)
@@ -415,15 +674,15 @@ bar
 
 func TestParseOutputNoEol(t *testing.T) {
 	cmds := []Command{
-		{"echo -n foo", nil, 0, 0},
-		{"echo -n bar", nil, 0, 0},
+		{CmdLine: "echo -n foo"},
+		{CmdLine: "echo -n bar"},
 	}
 	banner := "12345678-1234-abcd-1234-123412345678 ---"
 	output := []byte(`foo--- CRAM 0 12345678-1234-abcd-1234-123412345678 ---
 bar--- CRAM 1 12345678-1234-abcd-1234-123412345678 ---
 `)
 
-	executed, err := ParseOutput(cmds, output, banner)
+	executed, err := ParseOutput(cmds, output, banner, nil)
 	assert.NoError(t, err)
 	if assert.Len(t, executed, 2) {
 		assert.Equal(t, []string{"foo (no-eol)\n"}, executed[0].ActualOutput)
@@ -433,8 +692,185 @@ bar--- CRAM 1 12345678-1234-abcd-1234-123412345678 ---
 	}
 }
 
+func TestParseOutputSubstitutions(t *testing.T) {
+	cmds := []Command{
+		{CmdLine: "pwd"},
+		{CmdLine: "echo -n $PWD"},
+	}
+	banner := "12345678-abcd-1234-abcd-123412345678 ---"
+	output := []byte(`/tmp/cram-1/000-foo
+/tmp/cram-1/000-foo--- CRAM 0 12345678-abcd-1234-abcd-123412345678 ---
+--- CRAM 0 12345678-abcd-1234-abcd-123412345678 ---
+`)
+	subs := []Substitution{PathSubstitution("/tmp/cram-1/000-foo", "$TESTTMP")}
+
+	executed, err := ParseOutput(cmds, output, banner, subs)
+	assert.NoError(t, err)
+	if assert.Len(t, executed, 2) {
+		assert.Equal(t, []string{"$TESTTMP\n", "$TESTTMP (no-eol)\n"},
+			executed[0].ActualOutput)
+	}
+}
+
+func TestSubstitute(t *testing.T) {
+	subs := []Substitution{
+		PathSubstitution("/tmp/cram-1/000-foo", "$TESTTMP"),
+		PathSubstitution("/tmp/cram-1", "$CRAMTMP"),
+		{regexp.MustCompile(`(\d+) ms$`), "N ms"},
+	}
+	var tests = []struct {
+		input    string
+		expected string
+	}{
+		{"", ""},
+		{"foo\n", "foo\n"},
+		{"/tmp/cram-1/000-foo/x.txt\n", "$TESTTMP/x.txt\n"},
+		{"/tmp/cram-1/001-bar\n", "$CRAMTMP/001-bar\n"},
+		{"/tmp/cram-10\n", "/tmp/cram-10\n"},
+		{"/tmp/cram-1.bak/x\n", "/tmp/cram-1.bak/x\n"},
+		{"/tmp/cram-1: done\n", "$CRAMTMP: done\n"},
+		{"'/tmp/cram-1' /tmp/cram-1\n", "'$CRAMTMP' $CRAMTMP\n"},
+		{"took 42 ms\n", "took N ms\n"},
+		{"took 42 ms\r\n", "took N ms\r\n"},
+	}
+
+	for _, test := range tests {
+		actual := Substitute(test.input, subs)
+		assert.Equal(t, test.expected, actual,
+			fmt.Sprintf("Substitute(%#v)", test.input))
+	}
+}
+
+func TestEscapePlaceholders(t *testing.T) {
+	var tests = []struct {
+		input    string
+		expected string
+	}{
+		{"$TESTTMP/x\n", "$TESTTMP/x\n"},
+		{"$TESTTMP/x.* (glob)\n", "$TESTTMP/x.* (glob)\n"},
+		{"$TESTTMP/x.* (re)\n", `\$TESTTMP/x.* (re)` + "\n"},
+		{`\$TESTTMP/x.* (re)`, `\$TESTTMP/x.* (re)`},
+		{"$HOME$HOME (re)", `\$HOME\$HOME (re)`},
+		{"x$ (re)", "x$ (re)"},
+		{"$PATH (re)", "$PATH (re)"},
+	}
+
+	for _, test := range tests {
+		actual := escapePlaceholders(test.input)
+		assert.Equal(t, test.expected, actual,
+			fmt.Sprintf("escapePlaceholders(%#v)", test.input))
+	}
+}
+
+func TestAnsiSubstitution(t *testing.T) {
+	subs := []Substitution{AnsiSubstitution}
+	var tests = []struct {
+		input    string
+		expected string
+	}{
+		{"foo\n", "foo\n"},
+		{"\x1b[31mred\x1b[0m\n", "red\n"},
+		{"\x1b[1;4;32mgreen\x1b[m\n", "green\n"},
+		{"\x1b[2Jclear\n", "\x1b[2Jclear\n"},
+		{"\x1b[31m\r\n", "\r\n"},
+	}
+
+	for _, test := range tests {
+		actual := Substitute(test.input, subs)
+		assert.Equal(t, test.expected, actual,
+			fmt.Sprintf("Substitute(%#v)", test.input))
+	}
+}
+
+func TestMakePathSubstitutions(t *testing.T) {
+	subs, err := MakePathSubstitutions("/tmp/cram-1", "/tmp/cram-1/000-foo",
+		"/src/tests/foo.t")
+	assert.NoError(t, err)
+
+	var tests = []struct {
+		input    string
+		expected string
+	}{
+		{"/tmp/cram-1/000-foo/x.txt", "$TESTTMP/x.txt"},
+		{"/tmp/cram-1/001-bar", "$CRAMTMP/001-bar"},
+		{"cd /src/tests", "cd $TESTDIR"},
+		{os.Getenv("HOME") + "/.cramrc", "$HOME/.cramrc"},
+		{os.Getenv("HOME") + "use/x", os.Getenv("HOME") + "use/x"},
+	}
+
+	for _, test := range tests {
+		actual := Substitute(test.input, subs)
+		assert.Equal(t, test.expected, actual,
+			fmt.Sprintf("Substitute(%#v)", test.input))
+	}
+}
+
 func TestProcessInvalidPath(t *testing.T) {
-	test, err := Process("/tmp", "no-such-file.t", 0)
+	test, err := Process("/tmp", "no-such-file.t", 0, Options{})
 	assert.Equal(t, test.Path, "no-such-file.t")
 	assert.Error(t, err)
 }
+
+func TestValidateInvalidPath(t *testing.T) {
+	test, err := Validate("no-such-file.t", Options{})
+	assert.Equal(t, test.Path, "no-such-file.t")
+	assert.Error(t, err)
+}
+
+func TestPatchFile(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "cram-test-")
+	if !assert.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "test.t")
+	input := "  $ echo foo\n  bar\n"
+	assert.NoError(ioutil.WriteFile(path, []byte(input), 0751))
+	test, err := ParseTest(strings.NewReader(input), path)
+	assert.NoError(err)
+	cmds := []ExecutedCommand{{
+		Command:      &test.Cmds[0],
+		ActualOutput: []string{"foo\n"},
+	}}
+
+	assert.NoError(PatchFile(path, cmds, true))
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(err)
+	assert.Equal("  $ echo foo\n  foo\n", string(data))
+	info, err := os.Stat(path)
+	if assert.NoError(err) {
+		assert.Equal(os.FileMode(0751), info.Mode().Perm())
+	}
+	data, err = ioutil.ReadFile(path + ".orig")
+	assert.NoError(err)
+	assert.Equal(input, string(data))
+
+	// Only the test file and the backup are left in the directory.
+	names, err := filepath.Glob(filepath.Join(dir, "*"))
+	assert.NoError(err)
+	assert.Len(names, 2)
+}
+
+func TestPatchFileError(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "cram-test-")
+	if !assert.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	// An invalid test file cannot be patched and is left untouched.
+	path := filepath.Join(dir, "test.t")
+	input := "  foo\n"
+	assert.NoError(ioutil.WriteFile(path, []byte(input), 0644))
+	assert.Error(PatchFile(path, nil, false))
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(err)
+	assert.Equal(input, string(data))
+
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(err)
+	assert.Len(files, 1)
+}
@@ -0,0 +1,416 @@
+// Copyright 2016 Martin Geisler <martin@geisler.net>
+//
+// Cram is licensed under the MIT license, see the LICENSE file.
+
+package cram
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Document is a full-fidelity representation of a test file. Every
+// line of the file belongs to exactly one block and the lines are
+// kept exactly as they were read, so writing a parsed Document
+// reproduces the original file byte for byte.
+type Document struct {
+	Path   string  // Path to test file.
+	Blocks []Block // Commentary and command blocks in file order.
+	Eol    string  // Dominant EOL, used for inserted lines.
+}
+
+// ParseMode controls how line endings in a test file are parsed.
+type ParseMode int
+
+const (
+	// StrictEol treats a "\r" before the "\n" as part of the line.
+	// Commands and expected output in a file with "\r\n" line
+	// endings thus end with "\r".
+	StrictEol ParseMode = iota
+	// CRLFEol treats "\r\n" as the EOL. Commands and expected
+	// output are normalized to end with "\n". The raw lines of the
+	// Document are still kept as they are.
+	CRLFEol
+)
+
+// Block is a part of a Document. It is either a *Commentary or a
+// *CommandBlock.
+type Block interface {
+	// RawLines returns the lines of the block as they are
+	// written in the test file, including EOLs.
+	RawLines() []string
+}
+
+// Commentary is a run of lines which are not commands or output.
+// This includes blank lines and directives.
+type Commentary struct {
+	Lines []string // Raw lines.
+}
+
+// CommandBlock is a command with its continuation lines, the input
+// lines, the expected output, and the expected exit code.
+type CommandBlock struct {
+	Command      Command  // Parsed command.
+	CmdLines     []string // Raw command line and continuation lines.
+	InputLines   []string // Raw input lines.
+	OutputLines  []string // Raw expected output lines.
+	ExitCodeLine string   // Raw exit code line, empty if there is none.
+}
+
+func (c *Commentary) RawLines() []string {
+	return c.Lines
+}
+
+func (b *CommandBlock) RawLines() []string {
+	lines := append([]string{}, b.CmdLines...)
+	lines = append(lines, b.InputLines...)
+	lines = append(lines, b.OutputLines...)
+	if b.ExitCodeLine != "" {
+		lines = append(lines, b.ExitCodeLine)
+	}
+	return lines
+}
+
+// finishOutput moves a final "[n]" output line to the exit code
+// line once all output lines of the block have been seen.
+func (b *CommandBlock) finishOutput() {
+	lines := len(b.Command.ExpectedOutput)
+	updateExitCode(&b.Command)
+	if len(b.Command.ExpectedOutput) < lines {
+		b.ExitCodeLine = b.OutputLines[lines-1]
+		b.OutputLines = b.OutputLines[:lines-1]
+	}
+}
+
+// setOutput replaces the expected output lines of the block. The
+// lines are given without the output prefix. A final line without
+// EOL is marked with the (no-eol) suffix. Lines ending with "\n" are
+// changed to end with eol.
+func (b *CommandBlock) setOutput(lines []string, eol string) {
+	b.Command.ExpectedOutput = nil
+	b.OutputLines = nil
+	for i, line := range lines {
+		if !strings.HasSuffix(line, "\n") {
+			line += noEolSuffix + "\n"
+		}
+		line = protectPattern(line)
+		if i == 0 {
+			line = protectFirstOutput(line)
+		}
+		line = convertEol(line, eol)
+		b.Command.ExpectedOutput = append(b.Command.ExpectedOutput, line)
+		b.OutputLines = append(b.OutputLines, outputPrefix+line)
+	}
+}
+
+// protectPattern escapes a line whose suffix names a matcher but
+// which is not a valid pattern, such as "f( (re)". The actual output
+// matches such a line exactly, but the test file would be rejected
+// when parsed.
+func protectPattern(line string) string {
+	trimmed := DropEol(line)
+	if _, err := compileLine(trimmed); err == nil {
+		return line
+	}
+	return quoteLine(trimmed) + escSuffix + line[len(trimmed):]
+}
+
+// protectFirstOutput escapes the "<" in a first output line starting
+// with "< ". The line would otherwise be parsed as an input line.
+// Lines using other matchers are left alone.
+func protectFirstOutput(line string) string {
+	if !strings.HasPrefix(line, "< ") {
+		return line
+	}
+	trimmed := DropEol(line)
+	switch {
+	case strings.HasSuffix(trimmed, escSuffix):
+		return `\x3c` + line[1:]
+	case hasMatcherSuffix(trimmed):
+		return line
+	}
+	return `\x3c` + quoteLine(trimmed[1:]) + escSuffix + line[len(trimmed):]
+}
+
+// setExitCode replaces the expected exit code of the block. The exit
+// code line is removed for a zero exit code since [0] is implied.
+func (b *CommandBlock) setExitCode(exitCode int, eol string) {
+	b.Command.ExpectedExitCode = exitCode
+	b.ExitCodeLine = ""
+	if exitCode != 0 {
+		b.ExitCodeLine = fmt.Sprintf("%s[%d]%s", outputPrefix, exitCode, eol)
+	}
+}
+
+// withEol adds eol to line unless it already ends with "\n".
+func withEol(line, eol string) string {
+	if strings.HasSuffix(line, "\n") {
+		return line
+	}
+	return line + eol
+}
+
+// convertEol changes a final "\n" in line to eol. A final "\r\n" is
+// kept as it is.
+func convertEol(line, eol string) string {
+	if strings.HasSuffix(line, "\n") && !strings.HasSuffix(line, "\r\n") {
+		return line[:len(line)-1] + eol
+	}
+	return line
+}
+
+// dominantEol returns "\r\n" if most lines end with "\r\n" and "\n"
+// otherwise.
+func dominantEol(lines []string) string {
+	crlf, lf := 0, 0
+	for _, line := range lines {
+		switch {
+		case strings.HasSuffix(line, "\r\n"):
+			crlf++
+		case strings.HasSuffix(line, "\n"):
+			lf++
+		}
+	}
+	if crlf > lf {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// eol returns the EOL to use for lines inserted in the document.
+func (doc *Document) eol() string {
+	if doc.Eol == "" {
+		return "\n"
+	}
+	return doc.Eol
+}
+
+// ensureEol adds a missing EOL to the last command or input line.
+// This is needed before lines are added after a command at the end of
+// a file without a final EOL.
+func (b *CommandBlock) ensureEol(eol string) {
+	if n := len(b.InputLines); n > 0 {
+		b.InputLines[n-1] = withEol(b.InputLines[n-1], eol)
+		return
+	}
+	last := len(b.CmdLines) - 1
+	if !strings.HasSuffix(b.CmdLines[last], "\n") {
+		b.CmdLines[last] += eol
+		b.Command.CmdLine += "\n"
+	}
+}
+
+// ParseDocument parses a test file into a Document. Invalid lines
+// are reported as an InvalidTestError. The blocks parsed before the
+// error are returned together with the error.
+func ParseDocument(r io.Reader, path string) (Document, error) {
+	return ParseDocumentMode(r, path, StrictEol)
+}
+
+// ParseDocumentMode is like ParseDocument, but lets the caller
+// decide how line endings are parsed.
+func ParseDocumentMode(r io.Reader, path string, mode ParseMode) (
+	doc Document, err error) {
+	const (
+		inCommentary = iota
+		inCommand
+		inInput
+		inOutput
+	)
+
+	doc.Path = path
+	reader := bufio.NewReader(r)
+	state := inCommentary
+	var block *CommandBlock
+	var commentary *Commentary
+	lineno := 0
+	line := ""
+	rawLines := []string{}
+	// text returns the part of the line after the prefix. In CRLF
+	// mode, a final "\r\n" is normalized to "\n".
+	text := func(prefix string) string {
+		t := line[len(prefix):]
+		if mode == CRLFEol && strings.HasSuffix(t, "\r\n") {
+			t = t[:len(t)-2] + "\n"
+		}
+		return t
+	}
+	for err == nil {
+		line, err = reader.ReadString('\n')
+		rawLines = append(rawLines, line)
+		switch {
+		case strings.HasPrefix(line, commandPrefix):
+			if state == inOutput {
+				block.finishOutput()
+			}
+			block = &CommandBlock{
+				Command: Command{
+					CmdLine:   text(commandPrefix),
+					Lineno:    lineno + 1,
+					cmdLineno: lineno,
+				},
+				CmdLines: []string{line},
+			}
+			parseAnnotations(&block.Command)
+			doc.Blocks = append(doc.Blocks, block)
+			commentary = nil
+			state = inCommand
+		case strings.HasPrefix(line, continuationPrefix):
+			if state != inCommand {
+				err = &InvalidTestError{path, lineno + 1,
+					fmt.Sprintf("Continuation line %q has no command", line)}
+				return
+			}
+			block.CmdLines = append(block.CmdLines, line)
+			block.Command.CmdLine += text(continuationPrefix)
+			block.Command.Lineno++
+		case strings.HasPrefix(line, inputPrefix) &&
+			(state == inCommand || state == inInput):
+			// Input lines must come before the output, so later
+			// output lines can start with "< ".
+			block.InputLines = append(block.InputLines, line)
+			block.Command.Input = append(block.Command.Input,
+				text(inputPrefix))
+			block.Command.Lineno++
+			state = inInput
+		case strings.HasPrefix(line, outputPrefix):
+			if state == inCommentary {
+				err = &InvalidTestError{path, lineno + 1,
+					fmt.Sprintf("Output line %q has no command", line)}
+				return
+			}
+			output := text(outputPrefix)
+			// Compiling the line reports invalid patterns early and
+			// caches the result for later matching.
+			if _, e := compileLine(DropEol(output)); e != nil {
+				err = &InvalidTestError{path, lineno + 1,
+					fmt.Sprintf("Invalid output line %q: %s", output, e)}
+				return
+			}
+			block.OutputLines = append(block.OutputLines, line)
+			block.Command.ExpectedOutput = append(
+				block.Command.ExpectedOutput, output)
+			state = inOutput
+		default:
+			if state == inOutput {
+				block.finishOutput()
+			}
+			// The empty string is returned at EOF.
+			if line != "" {
+				if commentary == nil {
+					commentary = &Commentary{}
+					doc.Blocks = append(doc.Blocks, commentary)
+				}
+				commentary.Lines = append(commentary.Lines, line)
+			}
+			state = inCommentary
+		}
+		lineno++
+	}
+	if state == inOutput {
+		block.finishOutput()
+	}
+	doc.Eol = dominantEol(rawLines)
+	if err == io.EOF {
+		err = nil
+	}
+	return
+}
+
+// Test returns the commands and directives of the document.
+func (doc *Document) Test() Test {
+	test := Test{Path: doc.Path}
+	lineno := 0
+	for _, block := range doc.Blocks {
+		switch block := block.(type) {
+		case *Commentary:
+			for i, line := range block.Lines {
+				if strings.HasPrefix(line, directivePrefix) {
+					test.Directives = append(test.Directives,
+						parseDirective(line, lineno+i+1))
+				}
+			}
+		case *CommandBlock:
+			test.Cmds = append(test.Cmds, block.Command)
+		}
+		lineno += len(block.RawLines())
+	}
+	return test
+}
+
+// Write writes the lines of all blocks in the document to w.
+func (doc *Document) Write(w io.Writer) (err error) {
+	writer := bufio.NewWriter(w)
+	for _, block := range doc.Blocks {
+		for _, line := range block.RawLines() {
+			if _, err = writer.WriteString(line); err != nil {
+				return
+			}
+		}
+	}
+	return writer.Flush()
+}
+
+// AppendCommand adds a command block to the end of the document and
+// returns it. A command line spanning several lines is written with
+// continuation lines. The output lines are used as the expected
+// output as they are, so they should already be escaped.
+func (doc *Document) AppendCommand(cmdLine string, output []string,
+	exitCode int) *CommandBlock {
+	lineno := 0
+	for _, block := range doc.Blocks {
+		lineno += len(block.RawLines())
+	}
+	if n := len(doc.Blocks); n > 0 {
+		// Make sure the command starts on a line of its own.
+		switch block := doc.Blocks[n-1].(type) {
+		case *Commentary:
+			if l := len(block.Lines); l > 0 {
+				block.Lines[l-1] = withEol(block.Lines[l-1], doc.eol())
+			}
+		case *CommandBlock:
+			switch l := len(block.OutputLines); {
+			case block.ExitCodeLine != "":
+				block.ExitCodeLine = withEol(block.ExitCodeLine, doc.eol())
+			case l > 0:
+				block.OutputLines[l-1] = withEol(block.OutputLines[l-1],
+					doc.eol())
+			default:
+				block.ensureEol(doc.eol())
+			}
+		}
+	}
+
+	block := &CommandBlock{
+		Command: Command{CmdLine: cmdLine, cmdLineno: lineno},
+	}
+	prefix := commandPrefix
+	for _, line := range strings.SplitAfter(cmdLine, "\n") {
+		if line != "" {
+			block.CmdLines = append(block.CmdLines,
+				prefix+convertEol(line, doc.eol()))
+			prefix = continuationPrefix
+		}
+	}
+	block.Command.Lineno = lineno + len(block.CmdLines)
+	parseAnnotations(&block.Command)
+	block.ensureEol(doc.eol())
+	block.setOutput(output, doc.eol())
+	block.setExitCode(exitCode, doc.eol())
+	doc.Blocks = append(doc.Blocks, block)
+	return block
+}
+
+// commandBlock returns the command block whose first output line is
+// at the given 0-based line number, or nil if there is no such block.
+func (doc *Document) commandBlock(lineno int) *CommandBlock {
+	for _, block := range doc.Blocks {
+		if block, ok := block.(*CommandBlock); ok &&
+			block.Command.Lineno == lineno {
+			return block
+		}
+	}
+	return nil
+}
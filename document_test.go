@@ -0,0 +1,252 @@
+// Copyright 2016 Martin Geisler <martin@geisler.net>
+//
+// Cram is licensed under the MIT license, see the LICENSE file.
+
+package cram
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDocumentRoundTrip(t *testing.T) {
+	var tests = []string{
+		"",
+		"Commentary only\n",
+		"No final EOL",
+		"\n\n  $ echo foo\n  foo\n\n",
+		"  $ echo foo \\\n  > bar\n  foo bar\n",
+		"  $ false\n  [1]\n  $ true\n  [0]   \n",
+		"  $ printf foo\n  foo (no-eol)",
+		"  $ echo foo (unordered)\n  foo\n#cram: substitute s/a/b/\n",
+		"Windows\r\n  $ echo foo\r\n  foo\r\n",
+		"  $ cat\n  < foo\n  foo\n  < bar\n",
+		"  $ cat\n  < no eol",
+	}
+
+	for _, input := range tests {
+		doc, err := ParseDocument(strings.NewReader(input), "<string>")
+		msg := fmt.Sprintf("ParseDocument(%#v)", input)
+		if !assert.NoError(t, err, msg) {
+			continue
+		}
+		var buf bytes.Buffer
+		assert.NoError(t, doc.Write(&buf), msg)
+		assert.Equal(t, input, buf.String(), msg)
+	}
+}
+
+func TestDocumentBlocks(t *testing.T) {
+	assert := assert.New(t)
+	input := "Intro\n  $ echo \\\n  > foo\n  foo\n  [1]\n\nOutro\n"
+	doc, err := ParseDocument(strings.NewReader(input), "<string>")
+	assert.NoError(err)
+
+	if assert.Len(doc.Blocks, 3) {
+		assert.Equal(&Commentary{[]string{"Intro\n"}}, doc.Blocks[0])
+		assert.Equal(&CommandBlock{
+			Command: Command{
+				CmdLine:          "echo \\\nfoo\n",
+				ExpectedOutput:   []string{"foo\n"},
+				ExpectedExitCode: 1,
+				Lineno:           3,
+				cmdLineno:        1,
+			},
+			CmdLines:     []string{"  $ echo \\\n", "  > foo\n"},
+			OutputLines:  []string{"  foo\n"},
+			ExitCodeLine: "  [1]\n",
+		}, doc.Blocks[1])
+		assert.Equal(&Commentary{[]string{"\n", "Outro\n"}}, doc.Blocks[2])
+	}
+}
+
+func TestDocumentInput(t *testing.T) {
+	assert := assert.New(t)
+	input := "  $ cat\n  < foo\n  <  bar\n  foo\n  < baz\n  $ true\n"
+	doc, err := ParseDocument(strings.NewReader(input), "<string>")
+	assert.NoError(err)
+
+	if assert.Len(doc.Blocks, 2) {
+		assert.Equal(&CommandBlock{
+			Command: Command{
+				CmdLine:        "cat\n",
+				Input:          []string{"foo\n", " bar\n"},
+				ExpectedOutput: []string{"foo\n", "< baz\n"},
+				Lineno:         3,
+			},
+			CmdLines:    []string{"  $ cat\n"},
+			InputLines:  []string{"  < foo\n", "  <  bar\n"},
+			OutputLines: []string{"  foo\n", "  < baz\n"},
+		}, doc.Blocks[0])
+		cmd := doc.Blocks[0].(*CommandBlock).Command
+		assert.Equal(0, cmd.CmdLineno())
+	}
+}
+
+func TestDocumentTest(t *testing.T) {
+	assert := assert.New(t)
+	input := "#cram: substitute s/a/b/\n  $ echo a\n  b\n#cram: foo bar\n"
+	doc, err := ParseDocument(strings.NewReader(input), "<string>")
+	assert.NoError(err)
+
+	test := doc.Test()
+	assert.Equal("<string>", test.Path)
+	assert.Equal([]Directive{
+		{"substitute", "s/a/b/", 1},
+		{"foo", "bar", 4},
+	}, test.Directives)
+	if assert.Len(test.Cmds, 1) {
+		assert.Equal(Command{
+			CmdLine:        "echo a\n",
+			ExpectedOutput: []string{"b\n"},
+			Lineno:         2,
+			cmdLineno:      1,
+		}, test.Cmds[0])
+	}
+}
+
+func TestPatch(t *testing.T) {
+	var tests = []struct {
+		input    string
+		actual   []string
+		exitCode int
+		expected string
+	}{
+		{"  $ echo foo\n  bar\nAfter\n", []string{"foo\n"}, 0,
+			"  $ echo foo\n  foo\nAfter\n"},
+		{"  $ echo foo\n  [42]   \n", []string{}, 0,
+			"  $ echo foo\n"},
+		{"  $ false", []string{}, 1,
+			"  $ false\n  [1]\n"},
+		{"  $ (exit 7)\n  [10]\nAfter\n", []string{}, 7,
+			"  $ (exit 7)\n  [7]\nAfter\n"},
+		{"  $ true\n  [1]\n", []string{"foo\n"}, 0,
+			"  $ true\n  foo\n"},
+		{"  $ echo foo\n  foo\n  [0]\n", []string{"bar\n"}, 0,
+			"  $ echo foo\n  bar\n  [0]\n"},
+		{"  $ cat\n  < foo", []string{"foo\n"}, 0,
+			"  $ cat\n  < foo\n  foo\n"},
+		{"  $ cat\n  < a\\b\n", []string{"< a\\b\n", "< c\n"}, 0,
+			"  $ cat\n  < a\\b\n  \\x3c a\\\\b (esc)\n  < c\n"},
+		{"  $ cat\n", []string{"< \\t (esc)\n", "< .* (re)\n"}, 0,
+			"  $ cat\n  \\x3c \\t (esc)\n  < .* (re)\n"},
+		{"  $ cat\n", []string{"f( (re)\n", "\\q (esc)\n"}, 0,
+			"  $ cat\n  f( (re) (esc)\n  \\\\q (esc) (esc)\n"},
+		{"  $ cat\n", []string{"< f( (re)\n"}, 0,
+			"  $ cat\n  \\x3c f( (re) (esc)\n"},
+	}
+
+	for _, test := range tests {
+		parsed, err := ParseTest(strings.NewReader(test.input), "<string>")
+		msg := fmt.Sprintf("Patch(%#v)", test.input)
+		if !assert.NoError(t, err, msg) || !assert.Len(t, parsed.Cmds, 1) {
+			continue
+		}
+		cmd := ExecutedCommand{
+			Command:        &parsed.Cmds[0],
+			ActualOutput:   test.actual,
+			ActualExitCode: test.exitCode,
+		}
+
+		var buf bytes.Buffer
+		err = Patch(strings.NewReader(test.input), &buf, []ExecutedCommand{cmd})
+		if assert.NoError(t, err, msg) {
+			assert.Equal(t, test.expected, buf.String(), msg)
+		}
+	}
+}
+
+func TestDocumentAppendCommand(t *testing.T) {
+	assert := assert.New(t)
+	doc, err := ParseDocument(strings.NewReader("Intro"), "<string>")
+	assert.NoError(err)
+
+	doc.AppendCommand("echo foo\n", []string{"foo\n"}, 0)
+	doc.AppendCommand("printf \\\nbar (unordered)\n",
+		[]string{"bar"}, 1)
+
+	var buf bytes.Buffer
+	assert.NoError(doc.Write(&buf))
+	assert.Equal("Intro\n  $ echo foo\n  foo\n"+
+		"  $ printf \\\n  > bar (unordered)\n  bar (no-eol)\n  [1]\n",
+		buf.String())
+
+	test := doc.Test()
+	if assert.Len(test.Cmds, 2) {
+		assert.Equal(Command{
+			CmdLine:        "echo foo\n",
+			ExpectedOutput: []string{"foo\n"},
+			Lineno:         2,
+			cmdLineno:      1,
+		}, test.Cmds[0])
+		assert.Equal(Command{
+			CmdLine:          "printf \\\nbar\n",
+			ExpectedOutput:   []string{"bar (no-eol)\n"},
+			ExpectedExitCode: 1,
+			Lineno:           5,
+			Unordered:        true,
+			cmdLineno:        3,
+		}, test.Cmds[1])
+	}
+}
+
+func TestParseDocumentMode(t *testing.T) {
+	assert := assert.New(t)
+	input := "Intro\r\n  $ echo \\\r\n  > foo\r\n  foo\r\n  [1]\r\n"
+
+	doc, err := ParseDocumentMode(strings.NewReader(input), "<string>",
+		CRLFEol)
+	assert.NoError(err)
+	assert.Equal("\r\n", doc.Eol)
+	test := doc.Test()
+	if assert.Len(test.Cmds, 1) {
+		assert.Equal(Command{
+			CmdLine:          "echo \\\nfoo\n",
+			ExpectedOutput:   []string{"foo\n"},
+			ExpectedExitCode: 1,
+			Lineno:           3,
+			cmdLineno:        1,
+		}, test.Cmds[0])
+	}
+
+	// The raw lines are kept.
+	var buf bytes.Buffer
+	assert.NoError(doc.Write(&buf))
+	assert.Equal(input, buf.String())
+
+	doc, err = ParseDocument(strings.NewReader(input), "<string>")
+	assert.NoError(err)
+	test = doc.Test()
+	if assert.Len(test.Cmds, 1) {
+		assert.Equal("echo \\\r\nfoo\r\n", test.Cmds[0].CmdLine)
+		assert.Equal([]string{"foo\r\n"}, test.Cmds[0].ExpectedOutput)
+	}
+}
+
+func TestDominantEol(t *testing.T) {
+	assert.Equal(t, "\n", dominantEol(nil))
+	assert.Equal(t, "\n", dominantEol([]string{"a\r\n", "b\n"}))
+	assert.Equal(t, "\r\n", dominantEol([]string{"a\r\n", "b\r\n", "c\n"}))
+	assert.Equal(t, "\n", dominantEol([]string{"no eol"}))
+}
+
+func TestPatchCRLF(t *testing.T) {
+	input := "Intro\r\n  $ echo foo\r\n  bar\r\n  $ false\r\n"
+	test, err := ParseTest(strings.NewReader(input), "<string>")
+	if !assert.NoError(t, err) || !assert.Len(t, test.Cmds, 2) {
+		return
+	}
+	cmds := []ExecutedCommand{
+		{Command: &test.Cmds[0], ActualOutput: []string{"foo\n"}},
+		{Command: &test.Cmds[1], ActualExitCode: 1},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, Patch(strings.NewReader(input), &buf, cmds))
+	assert.Equal(t, "Intro\r\n  $ echo foo\r\n  foo\r\n  $ false\r\n  [1]\r\n",
+		buf.String())
+}
@@ -0,0 +1,112 @@
+// Copyright 2016 Martin Geisler <martin@geisler.net>
+//
+// Cram is licensed under the MIT license, see the LICENSE file.
+
+package cram
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Fingerprint returns a hash of everything the outcome of the test
+// file at path depends on: the test file itself, the environment
+// from MakeEnvironment, the options, the fixtures in opts.Fixtures,
+// and the programs or files in opts.Depends. Directives in the test
+// file are applied to opts first. If the fingerprint is unchanged, a
+// test which passed before is expected to pass again.
+func Fingerprint(path string, opts Options) (string, error) {
+	_, opts, err := load(path, opts)
+	if err != nil {
+		return "", err
+	}
+	env, err := MakeEnvironment(path)
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(env)
+
+	h := sha256.New()
+	if err = hashPath(h, path); err != nil {
+		return "", err
+	}
+	fmt.Fprintf(h, "env %q\n", env)
+	fmt.Fprintf(h, "parse-mode %d\npty %t\nstrip-ansi %t\nsandbox %t\n",
+		opts.ParseMode, opts.Pty, opts.StripAnsi, opts.Sandbox)
+	fmt.Fprintf(h, "limits %+v\n", opts.Limits)
+	for _, sub := range opts.Substitutions {
+		fmt.Fprintf(h, "substitute %q %q\n", sub.Pattern, sub.Replacement)
+	}
+
+	testdir := parseEnviron(env)["TESTDIR"]
+	for _, fixture := range opts.Fixtures {
+		fmt.Fprintf(h, "fixture %q\n", fixture)
+		if err = hashPath(h, filepath.Join(testdir, fixture)); err != nil {
+			return "", err
+		}
+	}
+	for _, name := range opts.Depends {
+		fmt.Fprintf(h, "depends %q\n", name)
+		program, err := exec.LookPath(name)
+		if err != nil {
+			// A missing program changes the fingerprint when it
+			// appears.
+			fmt.Fprintf(h, "missing\n")
+			continue
+		}
+		if err = hashPath(h, program); err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashPath adds the names and contents of the files at path to h.
+// Directories are walked recursively. A missing path is hashed as
+// such so that creating it changes the hash.
+func hashPath(h hash.Hash, path string) error {
+	resolved, err := filepath.EvalSymlinks(path)
+	if os.IsNotExist(err) {
+		fmt.Fprintf(h, "missing\n")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return filepath.Walk(resolved, func(name string, info os.FileInfo,
+		err error) error {
+		if err != nil {
+			return err
+		}
+		rel := strings.TrimPrefix(name, resolved)
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(name)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(h, "symlink %q %q\n", rel, target)
+		case info.Mode().IsRegular():
+			fmt.Fprintf(h, "file %q %d\n", rel, info.Size())
+			fp, err := os.Open(name)
+			if err != nil {
+				return err
+			}
+			defer fp.Close()
+			if _, err = io.Copy(h, fp); err != nil {
+				return err
+			}
+		default:
+			fmt.Fprintf(h, "%s %q\n", info.Mode()&os.ModeType, rel)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,64 @@
+// Copyright 2016 Martin Geisler <martin@geisler.net>
+//
+// Cram is licensed under the MIT license, see the LICENSE file.
+
+package cram
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFingerprint(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "cram-test-")
+	if !assert.NoError(err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "test.t")
+	fixture := filepath.Join(dir, "fixture.txt")
+	write := func(path, content string) {
+		assert.NoError(ioutil.WriteFile(path, []byte(content), 0644))
+	}
+
+	write(path, "#cram: fixture fixture.txt\n  $ true\n")
+	first, err := Fingerprint(path, Options{})
+	assert.NoError(err)
+	again, err := Fingerprint(path, Options{})
+	assert.NoError(err)
+	assert.Equal(first, again)
+
+	// A fixture appearing changes the fingerprint.
+	write(fixture, "1\n")
+	withFixture, err := Fingerprint(path, Options{})
+	assert.NoError(err)
+	assert.NotEqual(first, withFixture)
+
+	write(fixture, "2\n")
+	changed, err := Fingerprint(path, Options{})
+	assert.NoError(err)
+	assert.NotEqual(withFixture, changed)
+
+	withOpts, err := Fingerprint(path, Options{Pty: true})
+	assert.NoError(err)
+	assert.NotEqual(changed, withOpts)
+
+	withDepends, err := Fingerprint(path, Options{Depends: []string{"sh"}})
+	assert.NoError(err)
+	assert.NotEqual(changed, withDepends)
+
+	write(path, "  $ false\n")
+	other, err := Fingerprint(path, Options{})
+	assert.NoError(err)
+	assert.NotEqual(first, other)
+}
+
+func TestFingerprintInvalid(t *testing.T) {
+	_, err := Fingerprint("no-such-file.t", Options{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,107 @@
+// Copyright 2016 Martin Geisler <martin@geisler.net>
+//
+// Cram is licensed under the MIT license, see the LICENSE file.
+
+package cram
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// hasMatcherSuffix returns true if line (without EOL) ends with a
+// suffix naming a registered matcher.
+func hasMatcherSuffix(line string) bool {
+	_, name, _, ok := splitSuffix(line)
+	if !ok {
+		return false
+	}
+	_, ok = LookupMatcher(name)
+	return ok
+}
+
+// formatOutputLine returns the canonical form of an expected output
+// line (without EOL). Escape sequences are normalized by unescaping
+// and escaping the line again. Lines using other matchers are left
+// alone since escaping them would change the pattern.
+func formatOutputLine(line string, last bool) string {
+	if !strings.HasSuffix(line, escSuffix) {
+		if hasMatcherSuffix(line) {
+			return line
+		}
+		return Escape(line)
+	}
+
+	raw, err := Unescape(line)
+	if err != nil {
+		return line
+	}
+	// Escape keeps a final EOL, but an EOL in the middle of the
+	// test file would split the line in two.
+	if strings.ContainsAny(raw, "\r\n") {
+		return quoteLine(raw) + escSuffix
+	}
+	formatted := Escape(raw)
+	if formatted != raw {
+		return formatted
+	}
+
+	// The line needs no escaping, but dropping the (esc) suffix
+	// would expose a suffix or an exit code in the line itself.
+	_, isExitCode := parseExitCode(raw)
+	if hasMatcherSuffix(raw) || last && isExitCode {
+		return quoteLine(raw) + escSuffix
+	}
+	return raw
+}
+
+// formatOutput returns the canonical output and exit code lines for
+// a command block. The exit code line is written as "[n]" and dropped
+// for a zero exit code.
+func formatOutput(block *CommandBlock, eol string) (output []string,
+	exitCodeLine string) {
+	exitCode := block.Command.ExpectedExitCode
+	if block.ExitCodeLine != "" && exitCode != 0 {
+		exitCodeLine = fmt.Sprintf("%s[%d]%s", outputPrefix, exitCode, eol)
+	}
+	for i, line := range block.OutputLines {
+		content := DropEol(line)[len(outputPrefix):]
+		last := i == len(block.OutputLines)-1 && exitCodeLine == ""
+		formatted := formatOutputLine(content, last)
+		if i == 0 {
+			formatted = protectFirstOutput(formatted)
+		}
+		output = append(output, outputPrefix+formatted+eol)
+	}
+	return
+}
+
+// Format writes a canonical version of the test file read from r to
+// w. Expected output lines have their escape sequences normalized and
+// exit code lines are written as "[n]" (and dropped for "[0]").
+// Command, input and output lines end with the dominant line ending
+// of the file. Commentary is written unchanged. The path is used in
+// error messages.
+func Format(r io.Reader, w io.Writer, path string) error {
+	doc, err := ParseDocument(r, path)
+	if err != nil {
+		return err
+	}
+	eol := doc.eol()
+
+	for _, block := range doc.Blocks {
+		block, ok := block.(*CommandBlock)
+		if !ok {
+			continue
+		}
+		for i, line := range block.CmdLines {
+			block.CmdLines[i] = DropEol(line) + eol
+		}
+		for i, line := range block.InputLines {
+			block.InputLines[i] = DropEol(line) + eol
+		}
+		block.OutputLines, block.ExitCodeLine = formatOutput(block, eol)
+	}
+	return doc.Write(w)
+}
@@ -0,0 +1,93 @@
+// Copyright 2016 Martin Geisler <martin@geisler.net>
+//
+// Cram is licensed under the MIT license, see the LICENSE file.
+
+package cram
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatOutputLine(t *testing.T) {
+	var tests = []struct {
+		line     string
+		last     bool
+		expected string
+	}{
+		{"foo", false, "foo"},
+		{`\x78 (esc)`, false, "x"},
+		{`\x09 (esc)`, false, `\t (esc)`},
+		{"a\tb", false, `a\tb (esc)`},
+		{`foo\\ (esc)`, false, `foo\`},
+		{"fo+ (re)", false, "fo+ (re)"},
+		{"\t* (glob)", false, "\t* (glob)"},
+		{`foo (re) (esc)`, false, `foo (re) (esc)`},
+		{`foo \x28re) (esc)`, false, `foo (re) (esc)`},
+		{`[1] (esc)`, true, `[1] (esc)`},
+		{`[1] (esc)`, false, `[1]`},
+		{`foo\q (esc)`, false, `foo\q (esc)`},
+		{`foo\n (esc)`, false, `foo\n (esc)`},
+		{`foo\x0d\x0a (esc)`, false, `foo\r\n (esc)`},
+	}
+
+	for _, test := range tests {
+		actual := formatOutputLine(test.line, test.last)
+		msg := fmt.Sprintf("formatOutputLine(%#v, %v)", test.line, test.last)
+		assert.Equal(t, test.expected, actual, msg)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	var tests = []struct {
+		input    string
+		expected string
+	}{
+		{"", ""},
+		{"Commentary  \n", "Commentary  \n"},
+		{"\n\nfoo\n\n\n\nbar\n\n", "\n\nfoo\n\n\n\nbar\n\n"},
+		{"foo\r\n  $ true\n", "foo\r\n  $ true\n"},
+		{"no final newline", "no final newline"},
+		{"  $ false\n  [+1]\n", "  $ false\n  [1]\n"},
+		{"  $ echo '[1]  '\n  [1]  \n", "  $ echo '[1]  '\n  [1]  \n"},
+		{"  $ true\n  [0]\n", "  $ true\n"},
+		{"  $ echo [1]\n  [1] (esc)\n  [0]\n",
+			"  $ echo [1]\n  [1] (esc)\n"},
+		{"  $ echo [1]; false\n  [1] (esc)\n  [1]\n",
+			"  $ echo [1]; false\n  [1]\n  [1]\n"},
+		{"  $ echo 1\n  [1]\n  x\n", "  $ echo 1\n  [1]\n  x\n"},
+		{"  $ echo x\n  \\x78 (esc)\n  $ echo\n  \n",
+			"  $ echo x\n  x\n  $ echo\n  \n"},
+		{"  $ echo \\\n  >   x\n  x\n", "  $ echo \\\n  >   x\n  x\n"},
+		{"  $ cat\n  < x\n  \\x3c y (esc)\n  \\x3c z (esc)\n",
+			"  $ cat\n  < x\n  \\x3c y (esc)\n  < z\n"},
+		{"  $ cat (json)\n  [1,\\n (esc)\n  2]\n",
+			"  $ cat (json)\n  [1,\\n (esc)\n  2]\n"},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		err := Format(strings.NewReader(test.input), &buf, "<string>")
+		msg := fmt.Sprintf("Format(%#v)", test.input)
+		if assert.NoError(t, err, msg) {
+			assert.Equal(t, test.expected, buf.String(), msg)
+		}
+
+		// Formatting is idempotent.
+		var again bytes.Buffer
+		err = Format(&buf, &again, "<string>")
+		if assert.NoError(t, err, msg) {
+			assert.Equal(t, test.expected, again.String(), msg)
+		}
+	}
+}
+
+func TestFormatInvalid(t *testing.T) {
+	var buf bytes.Buffer
+	err := Format(strings.NewReader("  foo\n"), &buf, "<string>")
+	assert.Error(t, err)
+}
@@ -0,0 +1,141 @@
+// Copyright 2016 Martin Geisler <martin@geisler.net>
+//
+// Cram is licensed under the MIT license, see the LICENSE file.
+
+package cram
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"reflect"
+	"strings"
+)
+
+// jsonWildcard is a string value in the expected JSON output which
+// matches any actual value.
+const jsonWildcard = "<any>"
+
+// joinOutput turns output lines into the text they represent by
+// undoing the escaping done in ParseOutput.
+func joinOutput(lines []string) (string, error) {
+	text := []string{}
+	for _, line := range lines {
+		line, err := Unescape(line)
+		if err != nil {
+			return "", err
+		}
+		trimmed := DropEol(line)
+		if strings.HasSuffix(trimmed, noEolSuffix) {
+			line = trimmed[:len(trimmed)-len(noEolSuffix)]
+		}
+		text = append(text, line)
+	}
+	return strings.Join(text, ""), nil
+}
+
+// decodeJSON decodes output lines as a single JSON value. Numbers are
+// decoded as json.Number to keep them as written.
+func decodeJSON(lines []string) (value interface{}, err error) {
+	text, err := joinOutput(lines)
+	if err != nil {
+		return
+	}
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+	if err = decoder.Decode(&value); err != nil {
+		return
+	}
+	// The output must hold a single value.
+	var extra interface{}
+	if decoder.Decode(&extra) != io.EOF {
+		err = errors.New("unexpected data after JSON value")
+	}
+	return
+}
+
+// equalJSON compares decoded JSON values structurally. The string
+// jsonWildcard in expected matches any value in actual. Numbers are
+// compared exactly, so 1 equals 1.0 but large integers are not
+// rounded.
+func equalJSON(expected, actual interface{}) bool {
+	switch e := expected.(type) {
+	case json.Number:
+		a, ok := actual.(json.Number)
+		if !ok {
+			return false
+		}
+		x, okX := new(big.Rat).SetString(string(e))
+		y, okY := new(big.Rat).SetString(string(a))
+		return okX && okY && x.Cmp(y) == 0
+	case string:
+		if e == jsonWildcard {
+			return true
+		}
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !ok || len(a) != len(e) {
+			return false
+		}
+		for key, value := range e {
+			other, ok := a[key]
+			if !ok || !equalJSON(value, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		a, ok := actual.([]interface{})
+		if !ok || len(a) != len(e) {
+			return false
+		}
+		for i := range e {
+			if !equalJSON(e[i], a[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(expected, actual)
+}
+
+// matchJSON returns true if the expected and actual output lines are
+// both valid JSON and are structurally equal. Object keys can come in
+// any order and whitespace is ignored.
+func matchJSON(expected, actual []string) bool {
+	e, err := decodeJSON(expected)
+	if err != nil {
+		return false
+	}
+	a, err := decodeJSON(actual)
+	if err != nil {
+		return false
+	}
+	return equalJSON(e, a)
+}
+
+// formatJSON formats output lines holding a JSON value in a canonical
+// form: object keys are sorted and the value is indented by two
+// spaces. Numbers are kept as written.
+func formatJSON(lines []string) ([]string, error) {
+	value, err := decodeJSON(lines)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	formatted := []string{}
+	for _, line := range strings.SplitAfter(buf.String(), "\n") {
+		if line != "" {
+			formatted = append(formatted, line)
+		}
+	}
+	return formatted, nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2016 Martin Geisler <martin@geisler.net>
+//
+// Cram is licensed under the MIT license, see the LICENSE file.
+
+package cram
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchJSON(t *testing.T) {
+	var tests = []struct {
+		expected []string
+		actual   []string
+		match    bool
+	}{
+		{[]string{"{}\n"}, []string{"{}\n"}, true},
+		{[]string{"{}\n"}, []string{"{} (no-eol)\n"}, true},
+		{[]string{`{"a": 1, "b": 2}` + "\n"},
+			[]string{"{\n", `  "b": 2.0,` + "\n", `  "a": 1` + "\n", "}\n"}, true},
+		{[]string{`{"a": 1}` + "\n"}, []string{`{"a": 2}` + "\n"}, false},
+		{[]string{`{"a": 1}` + "\n"}, []string{`{"a": 1, "b": 2}` + "\n"}, false},
+		{[]string{`[1, 2]` + "\n"}, []string{`[2, 1]` + "\n"}, false},
+		{[]string{`[100, 0.5]` + "\n"}, []string{`[1e2, 5E-1]` + "\n"}, true},
+		{[]string{`{"id": 9007199254740993}` + "\n"},
+			[]string{`{"id": 9007199254740992}` + "\n"}, false},
+		{[]string{`[1]` + "\n"}, []string{`["1"]` + "\n"}, false},
+		{[]string{`{"id": "<any>", "ok": true}` + "\n"},
+			[]string{`{"id": [1, 2], "ok": true}` + "\n"}, true},
+		{[]string{`["<any>"]` + "\n"}, []string{`[]` + "\n"}, false},
+		{[]string{`{"tab": "\t"}` + "\n"}, []string{`{"tab": "\t"}` + "\n"}, true},
+		{[]string{"{}\n"}, []string{"not json\n"}, false},
+		{[]string{"{}\n"}, []string{"{} {}\n"}, false},
+		{[]string{"not json\n"}, []string{"not json\n"}, false},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.match, matchJSON(test.expected, test.actual),
+			fmt.Sprintf("matchJSON(%q, %q)", test.expected, test.actual))
+	}
+}
+
+func TestFormatJSON(t *testing.T) {
+	lines, err := formatJSON([]string{
+		`{"b": [1.50, "<x>"], "a": {}} (no-eol)` + "\n",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"{\n",
+		`  "a": {},` + "\n",
+		`  "b": [` + "\n",
+		`    1.50,` + "\n",
+		`    "<x>"` + "\n",
+		"  ]\n",
+		"}\n",
+	}, lines)
+
+	_, err = formatJSON([]string{"{\n"})
+	assert.Error(t, err)
+}
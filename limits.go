@@ -0,0 +1,106 @@
+// Copyright 2016 Martin Geisler <martin@geisler.net>
+//
+// Cram is licensed under the MIT license, see the LICENSE file.
+
+package cram
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Limits are resource limits for the shell running a test file and
+// the commands started by it. A zero value means no limit.
+type Limits struct {
+	CPU          uint64 // CPU time of each process in seconds.
+	AddressSpace uint64 // Virtual memory of each process in bytes.
+	OpenFiles    uint64 // Open files of each process.
+	Processes    uint64 // Processes of the user running the tests.
+	FileSize     uint64 // Size of files written in bytes.
+}
+
+// limitNames maps the names used by Set to the fields of Limits. The
+// names are the same as used by prlimit(1).
+var limitNames = map[string]func(l *Limits) *uint64{
+	"cpu":    func(l *Limits) *uint64 { return &l.CPU },
+	"as":     func(l *Limits) *uint64 { return &l.AddressSpace },
+	"nofile": func(l *Limits) *uint64 { return &l.OpenFiles },
+	"nproc":  func(l *Limits) *uint64 { return &l.Processes },
+	"fsize":  func(l *Limits) *uint64 { return &l.FileSize },
+}
+
+// limitUnits are the suffixes allowed on limit values.
+var limitUnits = map[string]uint64{
+	"K": 1 << 10,
+	"M": 1 << 20,
+	"G": 1 << 30,
+}
+
+// Set parses a limit of the form "name=value" and sets it. The name
+// is one of cpu, as, nofile, nproc, or fsize. The value can have a K,
+// M, or G suffix for multiples of 1024.
+func (l *Limits) Set(rule string) error {
+	i := strings.Index(rule, "=")
+	if i < 0 {
+		return fmt.Errorf("Limit %q must be of the form name=value", rule)
+	}
+	name, value := strings.TrimSpace(rule[:i]), strings.TrimSpace(rule[i+1:])
+	field, ok := limitNames[name]
+	if !ok {
+		return fmt.Errorf("Unknown limit %q, expected one of "+
+			"cpu, as, nofile, nproc, or fsize", name)
+	}
+
+	unit := uint64(1)
+	if n := len(value); n > 0 {
+		if u, ok := limitUnits[strings.ToUpper(value[n-1:])]; ok {
+			value, unit = value[:n-1], u
+		}
+	}
+	n, err := strconv.ParseUint(value, 10, 64)
+	if err != nil || n == 0 {
+		return fmt.Errorf("Limit %q must be a positive number", rule)
+	}
+	*field(l) = n * unit
+	return nil
+}
+
+// LimitViolation is a command which was killed because it exceeded
+// a resource limit. Only the cpu and fsize limits are detected since
+// they kill the command with a signal. Exceeding the other limits
+// makes system calls fail, which the command reports like any other
+// error.
+type LimitViolation struct {
+	ExecutedCommand
+	Limit string // Name of the exceeded limit, such as "cpu".
+}
+
+// findViolations splits the failed commands into commands killed by
+// a signal for an exceeded limit and other failures. Only limits set
+// in limits are considered since the signals can be sent for other
+// reasons.
+func findViolations(failures []ExecutedCommand, limits Limits) (
+	violations []LimitViolation, others []ExecutedCommand) {
+	for _, cmd := range failures {
+		name := limitSignal(cmd.ActualExitCode)
+		if name != "" && *limitNames[name](&limits) != 0 {
+			violations = append(violations, LimitViolation{cmd, name})
+		} else {
+			others = append(others, cmd)
+		}
+	}
+	return
+}
+
+// shellViolation returns the exit code to report for the command
+// running when the shell itself was killed by the signal of a limit
+// set in limits. Zero is returned if err is not from such a kill.
+func shellViolation(err error, limits Limits) int {
+	exitCode := signalExitCode(err)
+	name := limitSignal(exitCode)
+	if name == "" || *limitNames[name](&limits) == 0 {
+		return 0
+	}
+	return exitCode
+}
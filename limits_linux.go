@@ -0,0 +1,75 @@
+// Copyright 2016 Martin Geisler <martin@geisler.net>
+//
+// Cram is licensed under the MIT license, see the LICENSE file.
+
+package cram
+
+import (
+	"os/exec"
+	"syscall"
+	"unsafe"
+)
+
+// rlimitNproc is RLIMIT_NPROC, which is missing from the syscall
+// package.
+const rlimitNproc = 6
+
+// setLimits applies the limits to the running process with the given
+// pid. The limits are inherited by the processes it starts.
+func setLimits(pid int, limits Limits) error {
+	resources := []struct {
+		resource int
+		value    uint64
+	}{
+		{syscall.RLIMIT_CPU, limits.CPU},
+		{syscall.RLIMIT_AS, limits.AddressSpace},
+		{syscall.RLIMIT_NOFILE, limits.OpenFiles},
+		{rlimitNproc, limits.Processes},
+		{syscall.RLIMIT_FSIZE, limits.FileSize},
+	}
+	for _, r := range resources {
+		if r.value == 0 {
+			continue
+		}
+		rlimit := syscall.Rlimit{Cur: r.value, Max: r.value}
+		if r.resource == syscall.RLIMIT_CPU {
+			// SIGXCPU is sent at the soft limit and SIGKILL at the
+			// hard limit. The hard limit is one second higher so
+			// that SIGXCPU is seen first.
+			rlimit.Max++
+		}
+		_, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64,
+			uintptr(pid), uintptr(r.resource),
+			uintptr(unsafe.Pointer(&rlimit)), 0, 0, 0)
+		if errno != 0 {
+			return errno
+		}
+	}
+	return nil
+}
+
+// limitSignal returns the name of the limit whose signal makes the
+// shell report exitCode for a command. The empty string is returned
+// for other exit codes.
+func limitSignal(exitCode int) string {
+	switch exitCode {
+	case 128 + int(syscall.SIGXCPU):
+		return "cpu"
+	case 128 + int(syscall.SIGXFSZ):
+		return "fsize"
+	}
+	return ""
+}
+
+// signalExitCode returns the exit code reported by the shell for a
+// command killed by the signal which killed the process ending with
+// err. Zero is returned if err is not from a process killed by a
+// signal.
+func signalExitCode(err error) int {
+	if e, ok := err.(*exec.ExitError); ok {
+		if ws, ok := e.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			return 128 + int(ws.Signal())
+		}
+	}
+	return 0
+}
@@ -0,0 +1,36 @@
+// Copyright 2016 Martin Geisler <martin@geisler.net>
+//
+// Cram is licensed under the MIT license, see the LICENSE file.
+
+package cram
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindViolations(t *testing.T) {
+	cmds := []ExecutedCommand{
+		{Command: &Command{CmdLine: "cpu"}, ActualExitCode: 152},
+		{Command: &Command{CmdLine: "fsize"}, ActualExitCode: 153},
+		{Command: &Command{CmdLine: "false"}, ActualExitCode: 1},
+	}
+
+	violations, others := findViolations(cmds, Limits{CPU: 1})
+	assert.Equal(t, []LimitViolation{{cmds[0], "cpu"}}, violations)
+	assert.Equal(t, cmds[1:], others)
+
+	violations, others = findViolations(cmds, Limits{})
+	assert.Empty(t, violations)
+	assert.Equal(t, cmds, others)
+}
+
+func TestExecuteScriptLimits(t *testing.T) {
+	lines := []string{"ulimit -n\n"}
+	output, err := executeScript("", nil, lines,
+		Options{Limits: Limits{OpenFiles: 20}})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "20\n", string(output))
+	}
+}
@@ -0,0 +1,31 @@
+// Copyright 2016 Martin Geisler <martin@geisler.net>
+//
+// Cram is licensed under the MIT license, see the LICENSE file.
+
+//go:build !linux
+// +build !linux
+
+package cram
+
+import "errors"
+
+// setLimits is only supported on Linux. An error is returned on other
+// platforms if any limit is set.
+func setLimits(pid int, limits Limits) error {
+	if limits != (Limits{}) {
+		return errors.New("Resource limits are not supported on this platform")
+	}
+	return nil
+}
+
+// limitSignal returns the empty string since limits are not
+// supported on this platform.
+func limitSignal(exitCode int) string {
+	return ""
+}
+
+// signalExitCode returns zero since limits are not supported on this
+// platform.
+func signalExitCode(err error) int {
+	return 0
+}
@@ -0,0 +1,45 @@
+// Copyright 2016 Martin Geisler <martin@geisler.net>
+//
+// Cram is licensed under the MIT license, see the LICENSE file.
+
+package cram
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimitsSet(t *testing.T) {
+	var tests = []struct {
+		rule     string
+		expected Limits
+	}{
+		{"cpu=10", Limits{CPU: 10}},
+		{" as = 2G ", Limits{AddressSpace: 2 << 30}},
+		{"nofile=64", Limits{OpenFiles: 64}},
+		{"nproc=100", Limits{Processes: 100}},
+		{"fsize=1k", Limits{FileSize: 1024}},
+		{"fsize=3M", Limits{FileSize: 3 << 20}},
+	}
+
+	for _, test := range tests {
+		var limits Limits
+		err := limits.Set(test.rule)
+		msg := fmt.Sprintf("Set(%#v)", test.rule)
+		if assert.NoError(t, err, msg) {
+			assert.Equal(t, test.expected, limits, msg)
+		}
+	}
+}
+
+func TestLimitsSetInvalid(t *testing.T) {
+	var tests = []string{"cpu", "cpu=", "cpu=0", "cpu=-1", "cpu=1T",
+		"memory=1G", "=1"}
+
+	for _, rule := range tests {
+		var limits Limits
+		assert.Error(t, limits.Set(rule), fmt.Sprintf("Set(%#v)", rule))
+	}
+}
@@ -0,0 +1,239 @@
+// Copyright 2016 Martin Geisler <martin@geisler.net>
+//
+// Cram is licensed under the MIT license, see the LICENSE file.
+
+package cram
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// LineMatcher reports if an actual output line (without EOL) matches
+// a compiled expected output line.
+type LineMatcher func(actual string) bool
+
+// Matcher handles expected output lines ending with a suffix such as
+// " (re)". Matchers are registered under the name used in the suffix
+// with RegisterMatcher.
+type Matcher interface {
+	// Compile prepares an expected output line for matching. The
+	// pattern is the line without the suffix and EOL. For a suffix
+	// such as " (approx 5%)", args is the text following the name
+	// of the matcher, here "5%". An error is returned if the pattern
+	// or args are invalid. A nil LineMatcher with a nil error means
+	// that the suffix was not meant for this matcher and that the
+	// line should be compared literally.
+	Compile(pattern, args string) (LineMatcher, error)
+}
+
+// MatcherFunc is an adapter which allows an ordinary function to be
+// used as a Matcher.
+type MatcherFunc func(pattern, args string) (LineMatcher, error)
+
+// Compile calls f(pattern, args).
+func (f MatcherFunc) Compile(pattern, args string) (LineMatcher, error) {
+	return f(pattern, args)
+}
+
+var (
+	matchersMu sync.RWMutex
+	matchers   = make(map[string]Matcher)
+
+	// The compiled expected output lines are cached since the same
+	// lines are matched again and again, e.g., when a test file is
+	// processed more than once.
+	compiledMu sync.RWMutex
+	compiled   = make(map[string]compiledLine)
+)
+
+// compiledLine is the result of compiling an expected output line.
+type compiledLine struct {
+	m   LineMatcher
+	err error
+}
+
+// RegisterMatcher makes a matcher available for expected output
+// lines ending with " (name)" or " (name args)". It panics if a
+// matcher with the same name is already registered. Programs
+// embedding Cram will typically register their matchers in an init
+// function.
+func RegisterMatcher(name string, m Matcher) {
+	matchersMu.Lock()
+	defer matchersMu.Unlock()
+	if m == nil {
+		panic("cram: RegisterMatcher matcher is nil")
+	}
+	if _, dup := matchers[name]; dup {
+		panic("cram: RegisterMatcher called twice for " + name)
+	}
+	matchers[name] = m
+
+	// Lines compiled earlier might have a different meaning now.
+	compiledMu.Lock()
+	compiled = make(map[string]compiledLine)
+	compiledMu.Unlock()
+}
+
+// LookupMatcher returns the matcher registered under name.
+func LookupMatcher(name string) (m Matcher, ok bool) {
+	matchersMu.RLock()
+	defer matchersMu.RUnlock()
+	m, ok = matchers[name]
+	return
+}
+
+// Matchers returns the sorted names of the registered matchers.
+func Matchers() []string {
+	matchersMu.RLock()
+	defer matchersMu.RUnlock()
+	names := make([]string, 0, len(matchers))
+	for name := range matchers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// splitSuffix splits an expected output line (without EOL) of the
+// form "pattern (name args)" into its parts. The ok result is false
+// if the line has no suffix in parentheses.
+func splitSuffix(line string) (pattern, name, args string, ok bool) {
+	l := len(line)
+	if l == 0 || line[l-1] != ')' {
+		return
+	}
+	i := strings.LastIndex(line, " (")
+	if i < 0 {
+		return
+	}
+	inner := line[i+2 : l-1]
+	name = inner
+	if j := strings.IndexByte(inner, ' '); j >= 0 {
+		name, args = inner[:j], inner[j+1:]
+	}
+	return line[:i], name, args, name != ""
+}
+
+// compileLine compiles an expected output line (without EOL) using
+// the matcher named in its suffix. A nil LineMatcher is returned if
+// the line has no suffix naming a registered matcher. The result is
+// cached for lines with such a suffix, plain lines are not cached.
+func compileLine(expected string) (LineMatcher, error) {
+	pattern, name, args, ok := splitSuffix(expected)
+	if !ok {
+		return nil, nil
+	}
+	matcher, ok := LookupMatcher(name)
+	if !ok {
+		return nil, nil
+	}
+
+	compiledMu.RLock()
+	c, ok := compiled[expected]
+	compiledMu.RUnlock()
+	if !ok {
+		c.m, c.err = matcher.Compile(pattern, args)
+		compiledMu.Lock()
+		compiled[expected] = c
+		compiledMu.Unlock()
+	}
+	return c.m, c.err
+}
+
+// compileEntireLine compiles pattern into a LineMatcher which only
+// accepts lines matched by pattern in their entirety.
+func compileEntireLine(pattern string) (LineMatcher, error) {
+	// Compile the pattern by itself first so that errors quote the
+	// pattern as written in the test file.
+	if _, err := regexp.Compile(pattern); err != nil {
+		return nil, err
+	}
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return nil, err
+	}
+	return re.MatchString, nil
+}
+
+// globToRegexp translates a glob pattern into the corresponding
+// regular expression. We cannnot simply use filepath.Match since we
+// want "*" to match a sequence of any character instead of stopping
+// at "/" (or "\\" on Windows). Also, filepath.Match has a quirk where
+// there is no escaping on Windows.
+func globToRegexp(pattern string) string {
+	regexpMeta := `\.+*?()|[]{}^$`
+	buf := make([]byte, 2*len(pattern))
+	j := 0
+Loop:
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '?':
+			buf[j] = '.'
+		case '*':
+			buf[j], buf[j+1] = '.', '*'
+			j++
+		case '\\':
+			// Skip over the backslash
+			i++
+			if i == len(pattern) {
+				break Loop
+			}
+			// If we didn't break, add the next character to buf as a
+			// literal.
+			fallthrough
+		default:
+			// Escape character is necessary
+			if strings.IndexByte(regexpMeta, pattern[i]) >= 0 {
+				buf[j] = '\\'
+				j++
+			}
+			buf[j] = pattern[i]
+		}
+		j++
+	}
+	return string(buf[:j])
+}
+
+// noArgs wraps a matcher which takes no arguments in its suffix. A
+// suffix with arguments, such as "(esc to cancel)", is most likely
+// part of the output and is compared literally.
+func noArgs(compile func(pattern string) (LineMatcher, error)) Matcher {
+	return MatcherFunc(func(pattern, args string) (LineMatcher, error) {
+		if args != "" {
+			return nil, nil
+		}
+		return compile(pattern)
+	})
+}
+
+func init() {
+	// Regular expressions must match the entire line.
+	RegisterMatcher("re", noArgs(compileEntireLine))
+
+	// Glob patterns where "*" and "?" match any characters.
+	RegisterMatcher("glob", noArgs(func(pattern string) (LineMatcher, error) {
+		return compileEntireLine(globToRegexp(pattern))
+	}))
+
+	// Escaped output lines.
+	RegisterMatcher("esc", noArgs(func(pattern string) (LineMatcher, error) {
+		// The same output can be escaped in multiple differnet
+		// ways by the user: both "x (esc)" and "\x78 (esc)" are
+		// ways of saying "x". We normalize the output by
+		// unescaping and then escaping it. This ensures that the
+		// escaped form is the same as what was applied to the
+		// actual output in ParseOutput.
+		expected, err := Unescape(pattern + escSuffix)
+		if err != nil {
+			return nil, errors.New("invalid escape sequence")
+		}
+		escaped := Escape(expected)
+		return func(actual string) bool {
+			return escaped == actual
+		}, nil
+	}))
+}
@@ -0,0 +1,118 @@
+// Copyright 2016 Martin Geisler <martin@geisler.net>
+//
+// Cram is licensed under the MIT license, see the LICENSE file.
+
+package cram
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitSuffix(t *testing.T) {
+	var tests = []struct {
+		input   string
+		pattern string
+		name    string
+		args    string
+		ok      bool
+	}{
+		{"", "", "", "", false},
+		{"foo", "", "", "", false},
+		{"foo (re)", "foo", "re", "", true},
+		{"foo (bar) (re)", "foo (bar)", "re", "", true},
+		{"42 (approx 5%)", "42", "approx", "5%", true},
+		{"(re)", "", "", "", false},
+		{"foo ()", "", "", "", false},
+	}
+
+	for _, test := range tests {
+		pattern, name, args, ok := splitSuffix(test.input)
+		msg := fmt.Sprintf("splitSuffix(%#v)", test.input)
+		assert.Equal(t, test.ok, ok, msg)
+		if ok {
+			assert.Equal(t, test.pattern, pattern, msg)
+			assert.Equal(t, test.name, name, msg)
+			assert.Equal(t, test.args, args, msg)
+		}
+	}
+}
+
+func TestBuiltinMatchers(t *testing.T) {
+	assert.Equal(t, []string{"esc", "glob", "re"}, Matchers())
+
+	_, ok := LookupMatcher("re")
+	assert.True(t, ok)
+	_, ok = LookupMatcher("no-such-matcher")
+	assert.False(t, ok)
+
+	// Suffixes with arguments are not for the builtin matchers.
+	m, err := compileLine("press (esc to cancel)")
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+}
+
+func TestCompileLine(t *testing.T) {
+	m, err := compileLine("foo+ (re)")
+	if assert.NoError(t, err) {
+		assert.True(t, m("fooo"))
+		assert.False(t, m("bar"))
+	}
+
+	m, err = compileLine("foo")
+	assert.NoError(t, err)
+	assert.Nil(t, m)
+
+	_, err = compileLine("foo( (re)")
+	assert.EqualError(t, err,
+		"error parsing regexp: missing closing ): `foo(`")
+
+	// The result is cached, but only for lines with a matcher.
+	compiledMu.RLock()
+	_, ok := compiled["foo( (re)"]
+	assert.True(t, ok)
+	_, ok = compiled["foo"]
+	assert.False(t, ok)
+	compiledMu.RUnlock()
+}
+
+func TestRegisterMatcher(t *testing.T) {
+	// A matcher which accepts numbers within a given number of
+	// percent of the expected number.
+	approx := MatcherFunc(func(pattern, args string) (LineMatcher, error) {
+		expected, err := strconv.ParseFloat(pattern, 64)
+		if err != nil {
+			return nil, err
+		}
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(args, "%"), 64)
+		if err != nil {
+			return nil, err
+		}
+		return func(actual string) bool {
+			value, err := strconv.ParseFloat(actual, 64)
+			delta := expected * percent / 100
+			return err == nil && value >= expected-delta && value <= expected+delta
+		}, nil
+	})
+	RegisterMatcher("test-approx", approx)
+	defer func() {
+		matchersMu.Lock()
+		delete(matchers, "test-approx")
+		matchersMu.Unlock()
+	}()
+
+	assert.True(t, matchLine("100 (test-approx 5%)\n", "104\n"))
+	assert.False(t, matchLine("100 (test-approx 5%)\n", "106\n"))
+	assert.False(t, matchLine("100 (test-approx x%)\n", "100\n"))
+
+	assert.Panics(t, func() {
+		RegisterMatcher("test-approx", approx)
+	})
+	assert.Panics(t, func() {
+		RegisterMatcher("test-nil", nil)
+	})
+}
@@ -0,0 +1,110 @@
+// Copyright 2016 Martin Geisler <martin@geisler.net>
+//
+// Cram is licensed under the MIT license, see the LICENSE file.
+
+// Package pty opens pseudo-terminals. This lets commands run by Cram
+// write their output to a terminal while Cram reads the output from
+// the other end.
+package pty
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"syscall"
+	"unsafe"
+)
+
+func ioctl(f *os.File, request uintptr, arg unsafe.Pointer) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), request,
+		uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// Open opens a new pseudo-terminal and returns its master and slave
+// ends. The terminal is configured to not echo input and to not turn
+// "\n" into "\r\n" in the output. The output read from the master is
+// thus exactly what was written to the slave.
+func Open() (master, slave *os.File, err error) {
+	master, err = os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			master.Close()
+			if slave != nil {
+				slave.Close()
+			}
+		}
+	}()
+
+	var unlock int32
+	if err = ioctl(master, syscall.TIOCSPTLCK, unsafe.Pointer(&unlock)); err != nil {
+		return
+	}
+	var n uint32
+	if err = ioctl(master, syscall.TIOCGPTN, unsafe.Pointer(&n)); err != nil {
+		return
+	}
+	name := fmt.Sprintf("/dev/pts/%d", n)
+	slave, err = os.OpenFile(name, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return
+	}
+
+	var termios syscall.Termios
+	if err = ioctl(slave, syscall.TCGETS, unsafe.Pointer(&termios)); err != nil {
+		return
+	}
+	termios.Lflag &^= syscall.ECHO
+	termios.Oflag &^= syscall.ONLCR
+	err = ioctl(slave, syscall.TCSETS, unsafe.Pointer(&termios))
+	return
+}
+
+// IsTerminal returns true if f is a terminal. Unlike checking for a
+// character device, this is false for files such as /dev/null.
+func IsTerminal(f *os.File) bool {
+	var termios syscall.Termios
+	return ioctl(f, syscall.TCGETS, unsafe.Pointer(&termios)) == nil
+}
+
+// SetSize sets the window size of the terminal.
+func SetSize(f *os.File, rows, cols int) error {
+	size := struct{ rows, cols, x, y uint16 }{uint16(rows), uint16(cols), 0, 0}
+	return ioctl(f, syscall.TIOCSWINSZ, unsafe.Pointer(&size))
+}
+
+// Setup makes the slave the controlling terminal of cmd and connects
+// the stdout and stderr of cmd to it. The stdin of cmd is left alone
+// since nothing writes to the terminal: a command reading it would
+// wait forever. The command is started in a new session. Other
+// process attributes of cmd are kept.
+func Setup(cmd *exec.Cmd, slave *os.File) {
+	cmd.Stdout = slave
+	cmd.Stderr = slave
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setsid = true
+	cmd.SysProcAttr.Setctty = true
+	cmd.SysProcAttr.Ctty = 1 // The stdout of the child.
+}
+
+// ReadAll reads from the master until all processes have closed the
+// slave. Reading the master fails with EIO at that point, which is
+// reported as a normal end of file.
+func ReadAll(master *os.File) ([]byte, error) {
+	var buf bytes.Buffer
+	_, err := io.Copy(&buf, master)
+	if e, ok := err.(*os.PathError); ok && e.Err == syscall.EIO {
+		err = nil
+	}
+	return buf.Bytes(), err
+}
@@ -0,0 +1,53 @@
+// Copyright 2016 Martin Geisler <martin@geisler.net>
+//
+// Cram is licensed under the MIT license, see the LICENSE file.
+
+package pty
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpen(t *testing.T) {
+	master, slave, err := Open()
+	if err != nil {
+		t.Skip("cannot open pseudo-terminal:", err)
+	}
+	defer master.Close()
+	defer slave.Close()
+
+	_, err = slave.WriteString("foo\nbar\n")
+	assert.NoError(t, err)
+
+	buf := make([]byte, 100)
+	n, err := master.Read(buf)
+	assert.NoError(t, err)
+	// Newlines are not translated to "\r\n".
+	assert.Equal(t, "foo\nbar\n", string(buf[:n]))
+}
+
+func TestIsTerminal(t *testing.T) {
+	master, slave, err := Open()
+	if err != nil {
+		t.Skip("cannot open pseudo-terminal:", err)
+	}
+	defer master.Close()
+	defer slave.Close()
+	assert.True(t, IsTerminal(slave))
+
+	devNull, err := os.Open(os.DevNull)
+	if assert.NoError(t, err) {
+		defer devNull.Close()
+		assert.False(t, IsTerminal(devNull))
+	}
+
+	r, w, err := os.Pipe()
+	if assert.NoError(t, err) {
+		defer r.Close()
+		defer w.Close()
+		assert.False(t, IsTerminal(r))
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright 2016 Martin Geisler <martin@geisler.net>
+//
+// Cram is licensed under the MIT license, see the LICENSE file.
+
+//go:build !linux
+// +build !linux
+
+// Package pty opens pseudo-terminals. This lets commands run by Cram
+// write their output to a terminal while Cram reads the output from
+// the other end.
+package pty
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+)
+
+// Open is only supported on Linux. An error is returned on other
+// platforms.
+func Open() (master, slave *os.File, err error) {
+	err = errors.New("pseudo-terminals are not supported on this platform")
+	return
+}
+
+// IsTerminal is only supported on Linux. It returns false on other
+// platforms.
+func IsTerminal(f *os.File) bool {
+	return false
+}
+
+// SetSize does nothing on platforms without pseudo-terminals.
+func SetSize(f *os.File, rows, cols int) error {
+	return nil
+}
+
+// Setup connects the stdout and stderr of cmd to the slave. The stdin
+// of cmd is left alone.
+func Setup(cmd *exec.Cmd, slave *os.File) {
+	cmd.Stdout = slave
+	cmd.Stderr = slave
+}
+
+// ReadAll reads from the master until end of file.
+func ReadAll(master *os.File) ([]byte, error) {
+	return ioutil.ReadAll(master)
+}
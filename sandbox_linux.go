@@ -0,0 +1,300 @@
+// Copyright 2016 Martin Geisler <martin@geisler.net>
+//
+// Cram is licensed under the MIT license, see the LICENSE file.
+
+package cram
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// sandboxInitName is the argv[0] used when the running executable is
+// started again to set up a sandbox.
+const sandboxInitName = "cram-sandbox-init"
+
+// sandboxDirs are the system directories exposed read-only in the
+// sandbox. Directories missing on the host are skipped.
+var sandboxDirs = []string{
+	"/bin", "/sbin", "/usr", "/lib", "/lib32", "/lib64", "/libx32",
+	"/etc", "/opt",
+}
+
+func init() {
+	if len(os.Args) == 6 && os.Args[0] == sandboxInitName {
+		sandboxInit(os.Args[1:])
+	}
+}
+
+// sandbox runs a shell in new user, mount, and network namespaces.
+// The workdir is exposed read-write, the system directories and
+// $TESTDIR are exposed read-only, and the only network interface is
+// the loopback interface.
+//
+// The running executable is started again in the new namespaces.
+// It mounts the file system for the sandbox, reports the result on
+// file descriptor 4, and starts the shell once it has read a single
+// byte of the script on file descriptor 3.
+type sandbox struct {
+	root   string   // Empty directory used as root of the sandbox.
+	status *os.File // Setup errors are read from this pipe.
+	errors *os.File // Write end of the status pipe.
+}
+
+// newSandbox changes cmd from shellCommand to run the shell in a
+// sandbox. The sandbox must be closed after use.
+func newSandbox(cmd *exec.Cmd) (sb *sandbox, err error) {
+	root, err := ioutil.TempDir("", "cram-sandbox-")
+	if err != nil {
+		return
+	}
+	status, errors, err := os.Pipe()
+	if err != nil {
+		os.RemoveAll(root)
+		return
+	}
+	sb = &sandbox{root, status, errors}
+
+	testdir := parseEnviron(cmd.Env)["TESTDIR"]
+	cmd.Path = "/proc/self/exe"
+	cmd.Args = []string{sandboxInitName, root, cmd.Dir, testdir,
+		strconv.Itoa(os.Getuid()), strconv.Itoa(os.Getgid())}
+	cmd.ExtraFiles = append(cmd.ExtraFiles, errors)
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS |
+			syscall.CLONE_NEWNET,
+		UidMappings: []syscall.SysProcIDMap{
+			{ContainerID: 0, HostID: os.Getuid(), Size: 1},
+		},
+		GidMappings: []syscall.SysProcIDMap{
+			{ContainerID: 0, HostID: os.Getgid(), Size: 1},
+		},
+	}
+	return
+}
+
+// scriptPrefix is written before the script. The sandbox consumes it
+// when it starts the shell.
+func (sb *sandbox) scriptPrefix() string {
+	return "\n"
+}
+
+// startError explains an error from starting the sandbox.
+func (sb *sandbox) startError(err error) error {
+	return fmt.Errorf("Could not create sandbox: %s (unprivileged user "+
+		"namespaces may be disabled on this system)", err)
+}
+
+// ready waits until the sandbox has been set up and returns the
+// setup error, if any.
+func (sb *sandbox) ready() error {
+	sb.errors.Close()
+	msg, err := ioutil.ReadAll(sb.status)
+	if err != nil {
+		return err
+	}
+	if len(msg) > 0 {
+		return fmt.Errorf("Could not set up sandbox: %s",
+			strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// close releases the resources used by the sandbox.
+func (sb *sandbox) close() {
+	sb.status.Close()
+	sb.errors.Close()
+	os.RemoveAll(sb.root)
+}
+
+// sandboxInit is run in the new namespaces. It sets up the sandbox
+// and runs the shell. The process exits with the exit code of the
+// shell.
+func sandboxInit(args []string) {
+	root, workdir, testdir := args[0], args[1], args[2]
+	uid, _ := strconv.Atoi(args[3])
+	gid, _ := strconv.Atoi(args[4])
+
+	status := os.NewFile(4, "status")
+	if err := setupSandbox(root, workdir, testdir); err != nil {
+		fmt.Fprintln(status, err)
+		os.Exit(1)
+	}
+	status.Close()
+
+	// Wait until the parent has applied the resource limits.
+	script := os.NewFile(3, "script")
+	if _, err := script.Read(make([]byte, 1)); err != nil {
+		os.Exit(1)
+	}
+
+	// The shell runs in another user namespace where the user has
+	// the original user and group ID and no capabilities. It can
+	// thus not change the mounts made above.
+	cmd := exec.Command("/bin/sh", "/dev/fd/3")
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = []*os.File{script}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: syscall.CLONE_NEWUSER,
+		UidMappings: []syscall.SysProcIDMap{
+			{ContainerID: uid, HostID: 0, Size: 1},
+		},
+		GidMappings: []syscall.SysProcIDMap{
+			{ContainerID: gid, HostID: 0, Size: 1},
+		},
+	}
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	script.Close()
+	cmd.Wait()
+	ws := cmd.ProcessState.Sys().(syscall.WaitStatus)
+	if ws.Signaled() {
+		os.Exit(128 + int(ws.Signal()))
+	}
+	os.Exit(ws.ExitStatus())
+}
+
+// setupSandbox builds the file system of the sandbox in root and
+// makes it the root directory.
+func setupSandbox(root, workdir, testdir string) error {
+	// Keep the mounts below from propagating to the host.
+	err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, "")
+	if err != nil {
+		return fmt.Errorf("making mounts private: %s", err)
+	}
+	err = syscall.Mount("tmpfs", root, "tmpfs",
+		syscall.MS_NOSUID|syscall.MS_NODEV, "mode=0755")
+	if err != nil {
+		return fmt.Errorf("mounting tmpfs on %s: %s", root, err)
+	}
+
+	for _, dir := range sandboxDirs {
+		if err = exposeDir(root, dir, true); err != nil {
+			return err
+		}
+	}
+	for _, dir := range []string{"/dev", "/proc"} {
+		if err = exposeDir(root, dir, false); err != nil {
+			return err
+		}
+	}
+	if testdir != "" {
+		if err = exposeDir(root, testdir, true); err != nil {
+			return err
+		}
+	}
+	if err = exposeDir(root, workdir, false); err != nil {
+		return err
+	}
+	tmp := filepath.Join(root, "tmp")
+	if err = os.MkdirAll(tmp, 0755); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp, 01777); err != nil {
+		return err
+	}
+
+	// Make root the new root directory and detach the old root.
+	if err = os.Chdir(root); err != nil {
+		return err
+	}
+	if err = syscall.PivotRoot(".", "."); err != nil {
+		return fmt.Errorf("changing root directory: %s", err)
+	}
+	if err = syscall.Unmount(".", syscall.MNT_DETACH); err != nil {
+		return fmt.Errorf("detaching old root directory: %s", err)
+	}
+	if err = os.Chdir(workdir); err != nil {
+		return err
+	}
+	return loopbackUp()
+}
+
+// exposeDir makes dir from the host available at the same path below
+// root. Symlinks are copied as they are.
+func exposeDir(root, dir string, readOnly bool) error {
+	info, err := os.Lstat(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	target := filepath.Join(root, dir)
+	if info.Mode()&os.ModeSymlink != 0 {
+		link, err := os.Readlink(dir)
+		if err != nil {
+			return err
+		}
+		if err = os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		return os.Symlink(link, target)
+	}
+
+	if err = os.MkdirAll(target, 0755); err != nil {
+		return err
+	}
+	err = syscall.Mount(dir, target, "", syscall.MS_BIND|syscall.MS_REC, "")
+	if err != nil {
+		return fmt.Errorf("mounting %s: %s", dir, err)
+	}
+	if !readOnly {
+		return nil
+	}
+
+	// A bind mount is made read-only by remounting it. The flags of
+	// the original mount must be kept since they cannot be changed
+	// in a user namespace. Mounts below dir stay writable.
+	var stat syscall.Statfs_t
+	if err = syscall.Statfs(target, &stat); err != nil {
+		return err
+	}
+	keep := uintptr(syscall.MS_NOSUID | syscall.MS_NODEV |
+		syscall.MS_NOEXEC | syscall.MS_NOATIME | syscall.MS_NODIRATIME |
+		syscall.MS_RELATIME)
+	flags := syscall.MS_BIND | syscall.MS_REMOUNT | syscall.MS_RDONLY |
+		uintptr(stat.Flags)&keep
+	if err = syscall.Mount("", target, "", flags, ""); err != nil {
+		return fmt.Errorf("making %s read-only: %s", dir, err)
+	}
+	return nil
+}
+
+// loopbackUp brings up the loopback interface of the new network
+// namespace so that tests can use servers on localhost.
+func loopbackUp() error {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM, 0)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+
+	var ifreq struct {
+		name  [syscall.IFNAMSIZ]byte
+		flags uint16
+		_     [22]byte
+	}
+	copy(ifreq.name[:], "lo")
+	for _, request := range []uintptr{syscall.SIOCGIFFLAGS,
+		syscall.SIOCSIFFLAGS} {
+		_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd),
+			request, uintptr(unsafe.Pointer(&ifreq)))
+		if errno != 0 {
+			return fmt.Errorf("bringing up loopback interface: %s", errno)
+		}
+		ifreq.flags |= syscall.IFF_UP
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+// Copyright 2016 Martin Geisler <martin@geisler.net>
+//
+// Cram is licensed under the MIT license, see the LICENSE file.
+
+package cram
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExecuteScriptSandbox(t *testing.T) {
+	tempdir, err := ioutil.TempDir("", "cram-test-")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(tempdir)
+	workdir := filepath.Join(tempdir, "work")
+	outside := filepath.Join(tempdir, "outside")
+	assert.NoError(t, os.Mkdir(workdir, 0700))
+	assert.NoError(t, os.Mkdir(outside, 0700))
+
+	lines := []string{
+		"echo foo > foo.txt && cat foo.txt\n",
+		"ls " + outside + " 2> /dev/null || echo hidden\n",
+		"cat\n",
+	}
+	output, err := executeScript(workdir, os.Environ(), lines,
+		Options{Sandbox: true})
+	if err != nil && strings.HasPrefix(err.Error(), "Could not create sandbox") {
+		t.Skip(err)
+	}
+	if assert.NoError(t, err) {
+		assert.Equal(t, "foo\nhidden\n", string(output))
+	}
+}
@@ -0,0 +1,27 @@
+// Copyright 2016 Martin Geisler <martin@geisler.net>
+//
+// Cram is licensed under the MIT license, see the LICENSE file.
+
+//go:build !linux
+// +build !linux
+
+package cram
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// sandbox is only supported on Linux.
+type sandbox struct{}
+
+// newSandbox returns an error since the sandbox is only supported on
+// Linux.
+func newSandbox(cmd *exec.Cmd) (*sandbox, error) {
+	return nil, errors.New("The sandbox is only supported on Linux")
+}
+
+func (sb *sandbox) scriptPrefix() string       { return "" }
+func (sb *sandbox) startError(err error) error { return err }
+func (sb *sandbox) ready() error               { return nil }
+func (sb *sandbox) close()                     {}
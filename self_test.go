@@ -6,12 +6,26 @@ package cram
 
 import (
 	"os/exec"
+	"path/filepath"
 	"testing"
 )
 
-// TestSelf runs Cram on all .t files inside the tests directory.
+// TestSelf runs Cram on all .t files inside the tests directory. The
+// sandbox test is left out on systems without sandbox support.
 func TestSelf(t *testing.T) {
-	cmd := exec.Command("cram", "tests")
+	args := []string{"tests"}
+	if err := CheckSandbox(); err != nil {
+		t.Log("Not running tests/sandbox.t:", err)
+		paths, _ := filepath.Glob(filepath.Join("tests", "*.t"))
+		args = nil
+		for _, path := range paths {
+			if filepath.Base(path) != "sandbox.t" {
+				args = append(args, path)
+			}
+		}
+	}
+
+	cmd := exec.Command("cram", args...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		t.Log("Cram failed:", err)
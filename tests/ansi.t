@@ -0,0 +1,47 @@
+Colored output is shown with escape sequences:
+
+  $ cat > color.t << 'EOM'
+  >   $ printf '\033[1;31mred\033[0m and \033[32mgreen\033[m\n'
+  >   red and green
+  > EOM
+  $ cram color.t
+  F
+  When executing "printf '\\033[1;31mred\\033[0m and \\033[32mgreen\\033[m\\n'":
+  -red and green
+  +\x1b[1;31mred\x1b[0m and \x1b[32mgreen\x1b[m (esc)
+  # Ran 1 tests (1 commands), 0 errors, 1 failures
+  [1]
+
+With --strip-ansi, the ANSI color codes are removed before the output
+is compared:
+
+  $ cram --strip-ansi color.t
+  .
+  # Ran 1 tests (1 commands), 0 errors, 0 failures
+
+Other escape sequences are kept:
+
+  $ cat > cursor.t << 'EOM'
+  >   $ printf '\033[2Jclear\033[31m\n'
+  > EOM
+  $ cram --strip-ansi -u cursor.t
+  F
+  When executing "printf '\\033[2Jclear\\033[31m\\n'":
+  +\x1b[2Jclear (esc)
+  Patched cursor.t
+  # Ran 1 tests (1 commands), 0 errors, 1 failures
+  [1]
+  $ cram --strip-ansi cursor.t
+  .
+  # Ran 1 tests (1 commands), 0 errors, 0 failures
+
+Stripping can also be enabled with a directive in the test file:
+
+  $ (echo '#cram: strip-ansi'; cat color.t) > directive.t
+  $ cram directive.t
+  .
+  # Ran 1 tests (1 commands), 0 errors, 0 failures
+  $ (echo '#cram: strip-ansi off'; cat color.t) > off.t
+  $ cram --strip-ansi off.t > /dev/null
+  # Ran 1 tests (1 commands), 0 errors, 1 failures
+  [1]
@@ -0,0 +1,91 @@
+With --cache, tests which passed before are skipped if nothing they
+depend on has changed:
+
+  $ cat > simple.t << 'EOM'
+  >   $ echo hello
+  >   hello
+  > EOM
+  $ cram --cache simple.t
+  .
+  # Ran 1 tests (1 commands), 0 errors, 0 failures
+  $ cram --cache simple.t
+  c
+  # Ran 1 tests (1 commands), 0 errors, 0 failures, 1 cached
+  $ cram --cache -v simple.t
+  c simple.t: 1 commands passed before, not run
+  
+  # Ran 1 tests (1 commands), 0 errors, 0 failures, 1 cached
+
+Changing the test file, the environment, or the options runs the test
+again:
+
+  $ echo '  $ true' >> simple.t
+  $ cram --cache simple.t
+  .
+  # Ran 1 tests (2 commands), 0 errors, 0 failures
+  $ FOO=bar cram --cache simple.t
+  .
+  # Ran 1 tests (2 commands), 0 errors, 0 failures
+  $ cram --cache --strip-ansi simple.t
+  .
+  # Ran 1 tests (2 commands), 0 errors, 0 failures
+  $ cram --cache --strip-ansi simple.t
+  c
+  # Ran 1 tests (2 commands), 0 errors, 0 failures, 1 cached
+
+Fixtures used by a test are declared with a directive. The path is
+relative to the directory of the test file and can be a directory:
+
+  $ mkdir data
+  $ echo 1 > data/input.txt
+  $ cat > fixture.t << 'EOM'
+  > #cram: fixture data
+  >   $ cat "$TESTDIR/data/input.txt"
+  >   1
+  > EOM
+  $ cram --cache fixture.t
+  .
+  # Ran 1 tests (1 commands), 0 errors, 0 failures
+  $ cram --cache fixture.t
+  c
+  # Ran 1 tests (1 commands), 0 errors, 0 failures, 1 cached
+  $ echo 2 > data/input.txt
+  $ cram --cache fixture.t
+  F
+  When executing "cat \"$TESTDIR/data/input.txt\"":
+  -1
+  +2
+  # Ran 1 tests (1 commands), 0 errors, 1 failures
+  [1]
+
+Failed tests are never cached:
+
+  $ cram --cache fixture.t > /dev/null
+  # Ran 1 tests (1 commands), 0 errors, 1 failures
+  [1]
+
+Programs the tests depend on are given with --depends or a directive.
+They are found on $PATH:
+
+  $ mkdir bin
+  $ printf '#!/bin/sh\necho v1\n' > bin/tool
+  $ chmod +x bin/tool
+  $ export PATH="$PWD/bin:$PATH"
+  $ cat > tool.t << 'EOM'
+  >   $ tool
+  >   v1
+  > EOM
+  $ cram --cache --depends tool tool.t
+  .
+  # Ran 1 tests (1 commands), 0 errors, 0 failures
+  $ cram --cache --depends tool tool.t
+  c
+  # Ran 1 tests (1 commands), 0 errors, 0 failures, 1 cached
+  $ printf '#!/bin/sh\necho v2\n' > bin/tool
+  $ cram --cache --depends tool tool.t
+  F
+  When executing "tool":
+  -v1
+  +v2
+  # Ran 1 tests (1 commands), 0 errors, 1 failures
+  [1]
@@ -11,3 +11,15 @@ You can also use short options without a space:
   $ cram -ij 2 foo.t
   .
   # Ran 1 tests (0 commands), 0 errors, 0 failures
+
+The run command is the default command. This means that a test file
+or directory named after another command, such as config, fmt, help,
+lint or record, is taken as that command. Write the run command
+explicitly to run such a test file:
+
+  $ printf '  $ echo foo\n  foo\n' > lint
+  $ cram lint
+  # Linted 2 tests, 0 problems, 0 errors
+  $ cram run lint
+  .
+  # Ran 1 tests (1 commands), 0 errors, 0 failures
@@ -0,0 +1,171 @@
+Cram looks for a configuration file named .cramrc or cram.toml in the
+current directory and its parent directories. The effective settings
+are shown by the config command:
+
+  $ cram config
+  # No configuration file found
+  # Output matchers: esc, glob, re
+  interactive = false
+  verbose = false
+  debug = false
+  keep-tmp = false
+  jobs = \d+ (re)
+  substitute = []
+  lint = false
+  update = false
+  fill-empty = false
+  backup = false
+  crlf = false
+  pty = false
+  strip-ansi = false
+  limit = []
+  sandbox = false
+  retries = 0
+  last-failed = false
+  failed-first = false
+  cache = false
+  depends = []
+  keyword = ""
+
+The settings use the long names of the command line flags:
+
+  $ cat > .cramrc << EOM
+  > # Settings shared by the team
+  > jobs = 1
+  > verbose = true
+  > EOM
+  $ cram config
+  # Configuration file: $TESTTMP/.cramrc
+  # Output matchers: esc, glob, re
+  interactive = false
+  verbose = true
+  debug = false
+  keep-tmp = false
+  jobs = 1
+  substitute = []
+  lint = false
+  update = false
+  fill-empty = false
+  backup = false
+  crlf = false
+  pty = false
+  strip-ansi = false
+  limit = []
+  sandbox = false
+  retries = 0
+  last-failed = false
+  failed-first = false
+  cache = false
+  depends = []
+  keyword = ""
+
+The file is also found from a subdirectory:
+
+  $ mkdir sub
+  $ cd sub
+  $ touch foo.t bar.t
+  $ cram
+  . bar.t: 0 commands passed
+  . foo.t: 0 commands passed
+  
+  # Ran 2 tests (0 commands), 0 errors, 0 failures
+
+Flags given on the command line override the configuration file:
+
+  $ cram --no-verbose -j 3 config
+  # Configuration file: $TESTTMP/.cramrc
+  # Output matchers: esc, glob, re
+  interactive = false
+  verbose = false
+  debug = false
+  keep-tmp = false
+  jobs = 3
+  substitute = []
+  lint = false
+  update = false
+  fill-empty = false
+  backup = false
+  crlf = false
+  pty = false
+  strip-ansi = false
+  limit = []
+  sandbox = false
+  retries = 0
+  last-failed = false
+  failed-first = false
+  cache = false
+  depends = []
+  keyword = ""
+
+Values can be quoted, and the TOML spelling of a file name is also
+recognized:
+
+  $ cd ..
+  $ rm .cramrc
+  $ echo 'jobs = "4"' > cram.toml
+  $ cram config
+  # Configuration file: $TESTTMP/cram.toml
+  # Output matchers: esc, glob, re
+  interactive = false
+  verbose = false
+  debug = false
+  keep-tmp = false
+  jobs = 4
+  substitute = []
+  lint = false
+  update = false
+  fill-empty = false
+  backup = false
+  crlf = false
+  pty = false
+  strip-ansi = false
+  limit = []
+  sandbox = false
+  retries = 0
+  last-failed = false
+  failed-first = false
+  cache = false
+  depends = []
+  keyword = ""
+
+Settings for flags that can be repeated take a list of values:
+
+  $ cat > cram.toml << EOM
+  > substitute = ["s/foo/bar/", 's/\d+/N/']
+  > EOM
+  $ cram config
+  # Configuration file: $TESTTMP/cram.toml
+  # Output matchers: esc, glob, re
+  interactive = false
+  verbose = false
+  debug = false
+  keep-tmp = false
+  jobs = \d+ (re)
+  substitute = ["s/foo/bar/", "s/\\d+/N/"]
+  lint = false
+  update = false
+  fill-empty = false
+  backup = false
+  crlf = false
+  pty = false
+  strip-ansi = false
+  limit = []
+  sandbox = false
+  retries = 0
+  last-failed = false
+  failed-first = false
+  cache = false
+  depends = []
+  keyword = ""
+
+Unknown settings and malformed lines are reported:
+
+  $ echo 'color = true' > cram.toml
+  $ cram config
+  $TESTTMP/cram.toml:1: Unknown setting "color"
+  [2]
+
+  $ echo 'jobs' > cram.toml
+  $ cram config
+  $TESTTMP/cram.toml:1: Expected key = value, found "jobs"
+  [2]
@@ -40,7 +40,7 @@ You can patch such a command:
   +foo
   +bar
   +baz
-  Accept this change? Patched cont.t
+  Accept this change [y,n,a,d,q,e,c,?]? Patched cont.t
   # Ran 1 tests (1 commands), 0 errors, 1 failures
   [1]
 
@@ -0,0 +1,30 @@
+Test files checked out with CRLF line endings keep them when they are
+patched. The commands in this file are written with a trailing "\r"
+which the shell must not see, so the --crlf flag is needed to treat
+"\r\n" as the line ending:
+
+  $ printf '  $ echo foo\r\n  bar\r\n  $ false\r\n' > crlf.t
+  $ cram crlf.t > /dev/null
+  # Ran 1 tests (2 commands), 0 errors, 1 failures
+  [1]
+  $ cram --crlf --update crlf.t
+  F
+  When executing "echo foo":
+  -bar
+  +foo
+  When executing "false":
+  +[1]
+  Patched crlf.t
+  # Ran 1 tests (2 commands), 0 errors, 1 failures
+  [1]
+
+The inserted lines use CRLF like the rest of the file:
+
+  $ sed -n l crlf.t
+    $ echo foo\r$
+    foo\r$
+    $ false\r$
+    [1]\r$
+  $ cram --crlf crlf.t
+  .
+  # Ran 1 tests (2 commands), 0 errors, 0 failures
@@ -52,7 +52,7 @@ Patching works as expected:
   When executing "printf 'foo\\tbar\\n'":
   -foo bar
   +foo\tbar (esc)
-  Accept this change? Patched diff.t
+  Accept this change [y,n,a,d,q,e,c,?]? Patched diff.t
   # Ran 1 tests (1 commands), 0 errors, 1 failures
   [1]
 
@@ -0,0 +1,85 @@
+The fmt command rewrites test files in a canonical format. Escape
+sequences are normalized and exit code lines are cleaned up. The
+commentary is kept as it is:
+
+  $ cat > test.t << 'EOM'
+  > Commentary
+  > 
+  > 
+  >   $ printf 'x\t\n'
+  >   \x78\x09 (esc)
+  >   $ false
+  >   [01]
+  >   $ true
+  >   [0]
+  > 
+  > EOM
+  $ cram fmt test.t
+  test.t
+  # Formatted 1 of 1 tests, 0 errors
+  $ cat test.t
+  Commentary
+  
+  
+    $ printf 'x\t\n'
+    x\t (esc)
+    $ false
+    [1]
+    $ true
+  
+
+The formatted file still passes:
+
+  $ cram test.t
+  .
+  # Ran 1 tests (3 commands), 0 errors, 0 failures
+
+Formatting an already formatted file changes nothing:
+
+  $ cram fmt test.t
+  # Formatted 0 of 1 tests, 0 errors
+
+Escaped line endings are kept escaped since they would otherwise
+split the output line in two:
+
+  $ cat > eol.t << EOM
+  >   $ echo '[1, 2]' (json)
+  >   [1,\\n (esc)
+  >   2]
+  > EOM
+  $ cram eol.t
+  .
+  # Ran 1 tests (1 commands), 0 errors, 0 failures
+  $ cram fmt eol.t
+  # Formatted 0 of 1 tests, 0 errors
+  $ cat eol.t
+    $ echo '[1, 2]' (json)
+    [1,\n (esc)
+    2]
+
+The --check flag lists the files which are not formatted without
+changing them. This is useful in a CI system:
+
+  $ printf '  $ echo foo\n  \\x66oo (esc)\n' > unformatted.t
+  $ cram fmt --check test.t unformatted.t
+  unformatted.t
+  # 1 of 2 tests need formatting, 0 errors
+  [1]
+  $ cat unformatted.t
+    $ echo foo
+    \x66oo (esc)
+
+Files that cannot be parsed are reported as errors:
+
+  $ printf '  foo\n' > invalid.t
+  $ cram fmt invalid.t
+  invalid.t:1: Output line "  foo\n" has no command
+  # Formatted 0 of 1 tests, 1 errors
+  [2]
+
+Only whole files can be formatted:
+
+  $ cram fmt test.t:2
+  test.t:2: Only whole files can be formatted
+  # Formatted 0 of 1 tests, 1 errors
+  [2]
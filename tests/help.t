@@ -1,20 +1,53 @@
 Cram comes with builtin help:
 
   $ cram --help
-  usage: cram [<flags>] [<path>...]
+  usage: cram [<flags>] <command> [<args> ...]
   
   Flags:
-        --help         Show context-sensitive help (also try --help-long and
-                       --help-man).
-    -i, --interactive  interactively update test file on failure
-    -v, --verbose      show names of test files
-        --debug        output debug information
-        --keep-tmp     keep temporary directory after executing tests
-    -j, --jobs=\d+ +   number of tests to run in parallel (re)
-        --version      Show application version.
-  
-  Args:
-    [<path>]  test files or directories
+        --help                 Show context-sensitive help (also try --help-long
+                               and --help-man).
+    -i, --interactive          interactively update test file on failure
+    -v, --verbose              show names of test files
+        --debug                output debug information
+        --keep-tmp             keep temporary directory after executing tests
+    -j, --jobs=\d+ +           number of tests to run in parallel (re)
+        --substitute=RULE ...  replace output matching s/regexp/replacement/
+        --lint                 check test files for errors without running them
+    -u, --update               update test files with the actual output
+        --fill-empty           add the actual output to commands without output
+        --backup               keep the original of patched test files as .orig
+        --crlf                 treat CRLF in test files as line endings
+        --pty                  run commands with stdout and stderr on a terminal
+        --strip-ansi           remove ANSI color codes from the output
+        --limit=LIMIT ...      set a resource limit such as cpu=10 or fsize=1M
+        --sandbox              run commands in a sandbox without network access
+        --retries=N            run failed tests up to N more times
+        --last-failed          only run the tests which failed in the last run
+        --failed-first         run the tests which failed in the last run first
+        --cache                skip tests which passed before with the same inputs
+        --depends=PROGRAM ...  program the tests depend on, for --cache
+    -k, --keyword=PATTERN      only run test files with paths matching PATTERN
+        --version              Show application version.
+  
+  Commands:
+    help [<command>...]
+      Show help.
+  
+    run* [<path>...]
+      run test files (the default command)
+  
+    config
+      show the effective configuration
+  
+    lint [<path>...]
+      check test files for common mistakes
+  
+    fmt [<flags>] [<path>...]
+      rewrite test files in canonical format
+  
+    record <file>
+      record commands typed in a shell as a new test file
+  
   
 
 The traditional --version flag also works:
@@ -0,0 +1,69 @@
+Commands read stdin from /dev/null, so they cannot consume the
+following commands:
+
+  $ cat > stdin.t << 'EOM'
+  >   $ cat
+  >   $ echo still running
+  >   still running
+  > EOM
+  $ cram stdin.t
+  .
+  # Ran 1 tests (2 commands), 0 errors, 0 failures
+
+Input for a command is written on "  < " lines after the command. The
+input is passed literally, without any expansion:
+
+  $ cat > input.t << 'EOM'
+  >   $ tr a-z A-Z
+  >   < hello $USER
+  >   < world
+  >   HELLO $USER
+  >   WORLD
+  >   $ read line && echo "read: $line"; cat
+  >   < first
+  >   < second
+  >   read: first
+  >   second
+  > EOM
+  $ cram input.t
+  .
+  # Ran 1 tests (2 commands), 0 errors, 0 failures
+
+The input goes to the whole command line, including pipelines and
+continuation lines:
+
+  $ cat > pipe.t << 'EOM'
+  >   $ sort | \
+  >   > uniq -c | sed 's/^ *//'
+  >   < b
+  >   < a
+  >   < b
+  >   1 a
+  >   2 b
+  > EOM
+  $ cram pipe.t
+  .
+  # Ran 1 tests (1 commands), 0 errors, 0 failures
+
+Input lines come before the output. Older versions of Cram treated
+these lines as output, so a test file where the first output line of
+a command starts with "< ", as in the output of diff, now passes the
+line to the command instead. The lint command warns about input
+lines which look like output. When Cram writes output, a first output
+line starting with "<" is escaped:
+
+  $ cat > escaped.t << 'EOM'
+  >   $ printf '< a\n< b\n'
+  > EOM
+  $ cram -u escaped.t > /dev/null
+  # Ran 1 tests (1 commands), 0 errors, 1 failures
+  [1]
+  $ cat escaped.t
+    $ printf '< a\n< b\n'
+    \x3c a (esc)
+    < b
+  $ cram escaped.t
+  .
+  # Ran 1 tests (1 commands), 0 errors, 0 failures
+  $ cram fmt --check escaped.t
+  # 0 of 1 tests need formatting, 0 errors
@@ -16,7 +16,7 @@ a time:
   When executing "echo foo":
   -bar
   +foo
-  Accept this change? Patched test.t
+  Accept this change [y,n,a,d,q,e,c,?]? Patched test.t
   # Ran 1 tests (1 commands), 0 errors, 1 failures
   [1]
 
@@ -44,13 +44,13 @@ Here we accept the 'foo' and 'baz' outputs:
   When executing "echo foo":
   -first
   +foo
-  Accept this change? When executing "echo bar":
+  Accept this change [y,n,a,d,q,e,c,?]? When executing "echo bar":
   -second
   +bar
-  Accept this change? When executing "echo baz":
+  Accept this change [y,n,a,d,q,e,c,?]? When executing "echo baz":
   -third
   +baz
-  Accept this change? Patched multiple.t
+  Accept this change [y,n,a,d,q,e,c,?]? Patched multiple.t
   # Ran 1 tests (3 commands), 0 errors, 1 failures
   [1]
 
@@ -70,8 +70,8 @@ again:
   When executing "echo bar":
   -second
   +bar
-  Accept this change? Please answer 'yes' or 'no'
-  Accept this change? # Ran 1 tests (3 commands), 0 errors, 1 failures
+  Accept this change [y,n,a,d,q,e,c,?]? Please answer one of y, n, a, d, q, e, c, or ? for help
+  Accept this change [y,n,a,d,q,e,c,?]? # Ran 1 tests (3 commands), 0 errors, 1 failures
   [1]
 
 The file was not updated in this case:
@@ -101,11 +101,11 @@ You will also be prompted to update the exit code:
   When executing "(exit 7)":
   -[10]
   +[7]
-  Accept this change? When executing "false":
+  Accept this change [y,n,a,d,q,e,c,?]? When executing "false":
   +[1]
-  Accept this change? When executing "true":
+  Accept this change [y,n,a,d,q,e,c,?]? When executing "true":
   -[42]   
-  Accept this change? Patched exit-code.t
+  Accept this change [y,n,a,d,q,e,c,?]? Patched exit-code.t
   # Ran 1 tests (3 commands), 0 errors, 1 failures
   [1]
 
@@ -118,3 +118,128 @@ You will also be prompted to update the exit code:
     [1]
   White-space after the exit code:
     $ true
+
+Reviewing many changes
+======================
+
+Answering '?' shows the available choices:
+
+  $ cat > review.t << EOM
+  > Intro
+  > 
+  >   $ echo one
+  >   1
+  >   $ echo two
+  >   2
+  > 
+  > Outro
+  > EOM
+  $ printf '?\nn\nn\n' | cram --interactive review.t
+  F
+  When executing "echo one":
+  -1
+  +one
+  Accept this change [y,n,a,d,q,e,c,?]? y - accept this change
+  n - skip this change
+  a - accept this and all remaining changes in the file
+  d - skip this and all remaining changes in the file
+  q - quit, keeping the changes accepted so far
+  e - edit the expected output in $EDITOR
+  c - show the test file around the command
+  ? - show this help
+  Accept this change [y,n,a,d,q,e,c,?]? When executing "echo two":
+  -2
+  +two
+  Accept this change [y,n,a,d,q,e,c,?]? # Ran 1 tests (2 commands), 0 errors, 1 failures
+  [1]
+
+Answering 'c' shows the command in the context of the test file:
+
+  $ printf 'c\nn\nn\n' | cram --interactive review.t
+  F
+  When executing "echo one":
+  -1
+  +one
+  Accept this change [y,n,a,d,q,e,c,?]?    1- Intro
+     2- 
+     3:   $ echo one
+     4:   1
+     5-   $ echo two
+     6-   2
+     7- 
+  Accept this change [y,n,a,d,q,e,c,?]? When executing "echo two":
+  -2
+  +two
+  Accept this change [y,n,a,d,q,e,c,?]? # Ran 1 tests (2 commands), 0 errors, 1 failures
+  [1]
+
+Answering 'd' skips the remaining changes in the file and 'a' accepts
+them. Answering 'q' stops the review, but the changes accepted so far
+are still saved:
+
+  $ cp review.t other.t
+  $ printf 'd\na\n' | cram --interactive -j 1 review.t other.t > /dev/null
+  # Ran 2 tests (4 commands), 0 errors, 2 failures
+  [1]
+  $ cat review.t other.t | grep '^  [0-9a-z]'
+    1
+    2
+    one
+    two
+  $ cp other.t review.t
+  $ sed -i 's/^  one$/  1/; s/^  two$/  2/' review.t other.t
+  $ printf 'y\nq\n' | cram --interactive -j 1 review.t other.t
+  FF
+  When executing "echo one":
+  -1
+  +one
+  Accept this change [y,n,a,d,q,e,c,?]? When executing "echo two":
+  -2
+  +two
+  Accept this change [y,n,a,d,q,e,c,?]? Patched review.t
+  # Ran 2 tests (4 commands), 0 errors, 2 failures
+  [1]
+  $ cat review.t other.t | grep '^  [0-9a-z]'
+    one
+    2
+    1
+    2
+
+Running out of answers is the same as answering 'q':
+
+  $ sed -i 's/^  one$/  1/' review.t
+  $ echo y | cram --interactive review.t > /dev/null
+  # Ran 1 tests (2 commands), 0 errors, 1 failures
+  [1]
+  $ grep '^  [0-9a-z]' review.t
+    one
+    2
+
+Answering 'e' opens the block with the actual output in $EDITOR. The
+edited output is written to the test file:
+
+  $ cat > $TESTTMP/editor << 'EOM'
+  > #!/bin/sh
+  > sed -i 's/^  one$/  o.e (re)/' "$1"
+  > EOM
+  $ chmod +x $TESTTMP/editor
+  $ printf 'e\nn\n' | EDITOR=$TESTTMP/editor cram --interactive other.t
+  F
+  When executing "echo one":
+  -1
+  +one
+  Accept this change [y,n,a,d,q,e,c,?]? When executing "echo two":
+  -2
+  +two
+  Accept this change [y,n,a,d,q,e,c,?]? Patched other.t
+  # Ran 1 tests (2 commands), 0 errors, 1 failures
+  [1]
+  $ cat other.t
+  Intro
+  
+    $ echo one
+    o.e (re)
+    $ echo two
+    2
+  
+  Outro
@@ -2,7 +2,7 @@ Parsing a file with an output line before any command lines:
 
   $ echo '  This is an output line' > test.t
   $ cram test.t
-  test.t:0: Output line "  This is an output line\n" has no command
+  test.t:1: Output line "  This is an output line\n" has no command
   E
   # Ran 1 tests (0 commands), 1 errors, 0 failures
   [2]
@@ -17,7 +17,7 @@ a commentary line:
   >   Output line
   > EOM
   $ cram test.t
-  test.t:3: Output line "  Output line\n" has no command
+  test.t:4: Output line "  Output line\n" has no command
   E
   # Ran 1 tests (0 commands), 1 errors, 0 failures
   [2]
@@ -26,7 +26,7 @@ Parse invalid file with no final newline:
 
   $ echo -n '  ' > test.t
   $ cram test.t
-  test.t:0: Output line "  " has no command
+  test.t:1: Output line "  " has no command
   E
   # Ran 1 tests (0 commands), 1 errors, 0 failures
   [2]
@@ -35,7 +35,7 @@ Continuation line with no corresponding command:
 
   $ echo '  > continued' > test.t
   $ cram test.t
-  test.t:0: Continuation line "  > continued\n" has no command
+  test.t:1: Continuation line "  > continued\n" has no command
   E
   # Ran 1 tests (0 commands), 1 errors, 0 failures
   [2]
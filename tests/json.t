@@ -0,0 +1,62 @@
+Commands printing JSON can be marked with (json) at the end of the
+command line. The output is then compared as a JSON value, so the
+order of object keys and the whitespace do not matter:
+
+  $ echo '{"name": "cram", "tags": ["go", "test"], "stars": 42}' (json)
+  {
+    "stars": 42,
+    "name": "cram",
+    "tags": ["go", "test"]
+  }
+
+Values which change between runs can be matched with "<any>":
+
+  $ echo "{\"pid\": $$, \"ok\": true}" (json)
+  {"ok": true, "pid": "<any>"}
+
+Differences in the values are failures:
+
+  $ cat > test.t << EOM
+  >   $ echo '{"b": 2, "a": [1, 2.50, "<tag>"]}' (json)
+  >   {"a": [1], "b": 2}
+  > EOM
+  $ cram test.t
+  F
+  When executing "echo '{\"b\": 2, \"a\": [1, 2.50, \"<tag>\"]}'":
+  -{"a": [1], "b": 2}
+  +{"b": 2, "a": [1, 2.50, "<tag>"]}
+  # Ran 1 tests (1 commands), 0 errors, 1 failures
+  [1]
+
+When patching, the actual output is written in a canonical form with
+sorted keys:
+
+  $ yes | cram -i test.t > /dev/null
+  # Ran 1 tests (1 commands), 0 errors, 1 failures
+  [1]
+  $ cat test.t
+    $ echo '{"b": 2, "a": [1, 2.50, "<tag>"]}' (json)
+    {
+      "a": [
+        1,
+        2.50,
+        "<tag>"
+      ],
+      "b": 2
+    }
+  $ cram test.t
+  .
+  # Ran 1 tests (1 commands), 0 errors, 0 failures
+
+Output which is not valid JSON never matches and is written as is:
+
+  $ cat > invalid.t << EOM
+  >   $ echo '{"a":' (json)
+  >   {"a": 1}
+  > EOM
+  $ yes | cram -i invalid.t > /dev/null
+  # Ran 1 tests (1 commands), 0 errors, 1 failures
+  [1]
+  $ cat invalid.t
+    $ echo '{"a":' (json)
+    {"a":
@@ -0,0 +1,98 @@
+The outcome of each test is saved in .cram-cache in the current
+directory:
+
+  $ echo '  $ true' > a.t
+  $ echo '  $ false' > b.t
+  $ echo '  $ true' > c.t
+  $ echo '  $ false' > d.t
+  $ cram -j 1 a.t b.t c.t d.t
+  .F.F
+  When executing "false":
+  +[1]
+  When executing "false":
+  +[1]
+  # Ran 4 tests (4 commands), 0 errors, 2 failures
+  [1]
+  $ grep outcome .cram-cache/results.json
+      "outcome": "passed",
+      "outcome": "failed",
+      "outcome": "passed",
+      "outcome": "failed",
+
+With --failed-first, the tests which failed are run first:
+
+  $ cram -j 1 -v --failed-first a.t b.t c.t d.t
+  F b.t: 1 of 1 commands failed
+  F d.t: 1 of 1 commands failed
+  . a.t: 1 commands passed
+  . c.t: 1 commands passed
+  
+  When executing "false":
+  +[1]
+  When executing "false":
+  +[1]
+  # Ran 4 tests (4 commands), 0 errors, 2 failures
+  [1]
+
+With --last-failed, only the tests which failed are run:
+
+  $ echo '  $ true' > b.t
+  $ cram -j 1 -v --last-failed .
+  . b.t: 1 commands passed
+  F d.t: 1 of 1 commands failed
+  
+  When executing "false":
+  +[1]
+  # Ran 2 tests (2 commands), 0 errors, 1 failures
+  [1]
+
+The outcomes are updated after each run, so now only d.t is run:
+
+  $ cram -j 1 -v --last-failed .
+  F d.t: 1 of 1 commands failed
+  
+  When executing "false":
+  +[1]
+  # Ran 1 tests (1 commands), 0 errors, 1 failures
+  [1]
+  $ echo '  $ true' > d.t
+  $ cram --last-failed .
+  .
+  # Ran 1 tests (1 commands), 0 errors, 0 failures
+  $ cram --last-failed .
+  
+  # Ran 0 tests (0 commands), 0 errors, 0 failures
+
+Tests with errors are also run again:
+
+  $ echo '  > x' > e.t
+  $ cram e.t 2> /dev/null
+  E
+  [2]
+  $ cram --last-failed . 2> /dev/null
+  E
+  [2]
+
+Without a cache, --last-failed runs all tests:
+
+  $ rm -r .cram-cache
+  $ cram -j 1 --last-failed a.t b.t
+  ..
+  # Ran 2 tests (2 commands), 0 errors, 0 failures
+
+A test run up to a single line with file.t:LINE is not recorded as
+passed, since the commands after the line were not run:
+
+  $ printf '  $ true\n  $ false\n' > f.t
+  $ cram f.t > /dev/null
+  # Ran 1 tests (2 commands), 0 errors, 1 failures
+  [1]
+  $ cram f.t:1
+  .
+  # Ran 1 tests (1 commands), 0 errors, 0 failures
+  $ cram --last-failed f.t
+  F
+  When executing "false":
+  +[1]
+  # Ran 1 tests (2 commands), 0 errors, 1 failures
+  [1]
@@ -0,0 +1,87 @@
+Resource limits are applied to the shell running the commands and
+are inherited by the commands:
+
+  $ cat > limits.t << 'EOM'
+  >   $ ulimit -n
+  >   16
+  >   $ ulimit -t
+  >   10
+  > EOM
+  $ cram --limit nofile=16 --limit cpu=10 limits.t
+  .
+  # Ran 1 tests (2 commands), 0 errors, 0 failures
+
+Sizes can be given with a K, M, or G suffix. A command killed for
+exceeding the cpu or fsize limit is reported without a diff and is
+not patched. The other limits make system calls fail, so exceeding
+them is reported like any other failure:
+
+  $ cat > fsize.t << 'EOM'
+  >   $ head -c 2048 /dev/zero > big
+  >   $ wc -c < big
+  >   1024
+  >   $ echo done
+  > EOM
+  $ cram -u --limit fsize=1K fsize.t
+  F
+  When executing "head -c 2048 /dev/zero > big":
+  Killed for exceeding the fsize limit
+  When executing "echo done":
+  +done
+  Patched fsize.t
+  # Ran 1 tests (3 commands), 0 errors, 1 failures
+  [1]
+  $ cat fsize.t
+    $ head -c 2048 /dev/zero > big
+    $ wc -c < big
+    1024
+    $ echo done
+    done
+
+The limits can also be set with a directive in the test file:
+
+  $ cat > cpu.t << 'EOM'
+  > #cram: limit cpu=1
+  >   $ sh -c 'while :; do :; done'
+  >   $ echo still running
+  >   still running
+  > EOM
+  $ cram cpu.t
+  F
+  When executing "sh -c 'while :; do :; done'":
+  Killed for exceeding the cpu limit
+  # Ran 1 tests (2 commands), 0 errors, 1 failures
+  [1]
+
+The shell itself is killed when it exceeds the limit in a loop. The
+output of the commands before is still checked:
+
+  $ cat > loop.t << 'EOM'
+  > #cram: limit cpu=1
+  >   $ echo before
+  >   before
+  >   $ while :; do :; done
+  >   $ echo after
+  >   after
+  > EOM
+  $ cram loop.t
+  F
+  When executing "while :; do :; done":
+  Killed for exceeding the cpu limit
+  # Ran 1 tests (3 commands), 0 errors, 1 failures
+  [1]
+
+Invalid limits are reported:
+
+  $ cram --limit cpu=x cpu.t
+  Limit "cpu=x" must be a positive number
+  [2]
+  $ cram --limit memory=1G cpu.t
+  Unknown limit "memory", expected one of cpu, as, nofile, nproc, or fsize
+  [2]
+  $ echo '#cram: limit cpu' > invalid.t
+  $ cram invalid.t
+  invalid.t:1: Limit "cpu" must be of the form name=value
+  E
+  # Ran 1 tests (0 commands), 1 errors, 0 failures
+  [2]
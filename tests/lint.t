@@ -0,0 +1,135 @@
+The --lint flag checks test files without executing them. Output lines
+with invalid (re), (glob) or (esc) patterns are reported with their
+line numbers:
+
+  $ cat > re.t << EOM
+  >   $ echo foo
+  >   foo[ (re)
+  > EOM
+  $ cat > esc.t << EOM
+  > Commentary
+  >   $ printf 'a\tb\n'
+  >   a\qb (esc)
+  > EOM
+  $ cat > good.t << EOM
+  >   $ touch marker
+  >   $ echo foo
+  >   f* (glob)
+  > EOM
+  $ cram --lint -j 1 re.t esc.t good.t
+  re.t:2: Invalid output line "foo[ (re)\n": error parsing regexp: missing closing ]: `[`
+  esc.t:3: Invalid output line "a\\qb (esc)\n": invalid escape sequence
+  # Linted 3 tests, 0 problems, 2 errors
+  [2]
+
+Nothing was executed:
+
+  $ ls
+  esc.t
+  good.t
+  re.t
+
+Directives are also checked:
+
+  $ echo '#cram: substitute s/(/x/' > directive.t
+  $ cram --lint directive.t
+  directive.t:1: Substitution "s/(/x/" has invalid pattern: error parsing regexp: missing closing ): `(`
+  # Linted 1 tests, 0 problems, 1 errors
+  [2]
+  $ cram lint directive.t
+  directive.t:1: Substitution "s/(/x/" has invalid pattern: error parsing regexp: missing closing ): `(`
+  # Linted 1 tests, 0 problems, 1 errors
+  [2]
+
+The exit code is zero when all files are valid:
+
+  $ cram --lint good.t
+  # Linted 1 tests, 0 problems, 0 errors
+
+The lint command
+================
+
+The lint command reports the same errors as the --lint flag. Besides
+the errors above, it looks for mistakes which are not errors, but
+which make a test file behave differently from what the author
+intended:
+
+  $ printf '  $ echo foo\n   $ echo bar\n $ echo baz\n' > indent.t
+  $ printf '  $ echo foo\n\tfoo\n' > tabs.t
+  $ printf '  $ false\n  [1]  \n  $ echo foo\n  f* (glob) \n' > trailing.t
+  $ cat > exit-code.t << EOM
+  >   $ false; echo foo
+  >   [1]
+  >   foo
+  > EOM
+  $ cat > exit.t << EOM
+  >   $ exit 1
+  >   $ echo unreachable
+  >   unreachable
+  > EOM
+  $ cat > re-lint.t << EOM
+  >   $ echo foo
+  >   foo (re)
+  >   $ echo foo
+  >   fo+ (re)
+  > EOM
+  $ cat > diff.t << 'EOM'
+  >   $ diff a b
+  >   < old
+  >   $ echo foo | diff - b
+  >   < f* (glob)
+  >   [1]
+  > EOM
+  $ cram lint indent.t tabs.t trailing.t exit-code.t exit.t re-lint.t diff.t
+  indent.t:2: Command is indented by 3 spaces instead of 2
+  indent.t:3: Command is indented by 1 spaces instead of 2
+  tabs.t:2: Indentation uses tabs instead of spaces
+  trailing.t:2: Trailing whitespace after "[1]"
+  trailing.t:4: Trailing whitespace after " (glob)"
+  exit-code.t:2: Exit code [1] is not on the last output line
+  exit.t:2: Command is never executed due to exit on line 1
+  re-lint.t:2: Pattern "foo" has no special characters, the (re) suffix is not needed
+  diff.t:2: Input lines are not followed by output, escape "<" as \x3c if they are output
+  diff.t:4: Input line "f* (glob)" looks like output
+  # Linted 7 tests, 10 problems, 0 errors
+  [1]
+
+Files that cannot be parsed are reported as errors:
+
+  $ cram lint re.t good.t
+  re.t:2: Invalid output line "foo[ (re)\n": error parsing regexp: missing closing ]: `[`
+  # Linted 2 tests, 0 problems, 1 errors
+  [2]
+
+The exit code is zero when no problems are found:
+
+  $ cram lint good.t
+  # Linted 1 tests, 0 problems, 0 errors
+
+The --lint flag only reports errors, not these mistakes:
+
+  $ cram --lint indent.t
+  # Linted 1 tests, 0 problems, 0 errors
+
+The test files can be selected like when running them. With
+file.t:LINE, only the mistakes in the command on that line are
+reported:
+
+  $ cram lint -k 'in*' .
+  indent.t:2: Command is indented by 3 spaces instead of 2
+  indent.t:3: Command is indented by 1 spaces instead of 2
+  # Linted 1 tests, 2 problems, 0 errors
+  [1]
+  $ printf '  $ echo foo\n   $ echo bar\n  $ echo baz\n   $ echo qux\n' > more.t
+  $ cram lint more.t:3
+  more.t:4: Command is indented by 3 spaces instead of 2
+  # Linted 1 tests, 1 problems, 0 errors
+  [1]
+  $ cram lint more.t:5
+  more.t:5: No command on this line
+  # Linted 1 tests, 0 problems, 1 errors
+  [2]
+  $ cram --lint more.t:5
+  more.t:5: No command on this line
+  # Linted 1 tests, 0 problems, 1 errors
+  [2]
@@ -31,10 +31,10 @@ necessary:
   When executing "echo -n hello":
   -hello
   +hello (no-eol)
-  Accept this change? When executing "echo world":
+  Accept this change [y,n,a,d,q,e,c,?]? When executing "echo world":
   -world (no-eol)
   +world
-  Accept this change? Patched diff.t
+  Accept this change [y,n,a,d,q,e,c,?]? Patched diff.t
   # Ran 1 tests (2 commands), 0 errors, 1 failures
   [1]
 
@@ -0,0 +1,49 @@
+Commands normally write their output to a pipe:
+
+  $ cat > tty.t << 'EOM'
+  >   $ [ -t 1 ] && echo stdout is a terminal
+  >   stdout is a terminal
+  >   $ [ -t 2 ] && echo stderr is a terminal
+  >   stderr is a terminal
+  >   $ stty size < /dev/tty
+  >   24 80
+  >   $ printf 'no eol'
+  >   no eol (no-eol)
+  > EOM
+  $ cram tty.t > /dev/null
+  # Ran 1 tests (4 commands), 0 errors, 1 failures
+  [1]
+
+With --pty, the output goes to a pseudo-terminal instead. The size of
+the terminal is taken from $COLUMNS:
+
+  $ cram --pty tty.t
+  .
+  # Ran 1 tests (4 commands), 0 errors, 0 failures
+
+Only the output goes to the terminal. Stdin is not connected to it,
+so a command reading stdin gets end of file instead of waiting for
+input which never comes. Programs which check if stdin is a terminal
+before prompting therefore behave as if they run in a pipeline:
+
+  $ cat > stdin.t << 'EOM'
+  >   $ [ -t 0 ] || echo stdin is not a terminal
+  >   stdin is not a terminal
+  >   $ cat
+  > EOM
+  $ cram --pty stdin.t
+  .
+  # Ran 1 tests (2 commands), 0 errors, 0 failures
+
+The pty mode can also be enabled with a directive in the test file:
+
+  $ (echo '#cram: pty'; cat tty.t) > directive.t
+  $ cram directive.t
+  .
+  # Ran 1 tests (4 commands), 0 errors, 0 failures
+  $ echo '#cram: pty maybe' > invalid.t
+  $ cram invalid.t
+  invalid.t:1: Invalid value "maybe" for pty directive, expected on or off
+  E
+  # Ran 1 tests (0 commands), 1 errors, 0 failures
+  [2]
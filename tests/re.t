@@ -41,3 +41,35 @@ The pattern is anchored at the beginning and the end of the line:
   +foobar
   # Ran 1 tests (2 commands), 0 errors, 1 failures
   [1]
+
+Invalid regular expressions are reported when the test file is read:
+
+  $ cat > invalid.t << EOM
+  >   $ echo foo
+  >   fo(o (re)
+  > EOM
+  $ cram invalid.t
+  invalid.t:2: Invalid output line "fo(o (re)\n": error parsing regexp: missing closing ): `fo(o`
+  E
+  # Ran 1 tests (0 commands), 1 errors, 0 failures
+  [2]
+
+Output which looks like an invalid regular expression is escaped when
+it is added to a test file, so the file can still be read:
+
+  $ cat > literal.t << EOM
+  >   $ echo 'call f( (re)'
+  > EOM
+  $ cram -u literal.t
+  F
+  When executing "echo 'call f( (re)'":
+  +call f( (re) (esc)
+  Patched literal.t
+  # Ran 1 tests (1 commands), 0 errors, 1 failures
+  [1]
+  $ cat literal.t
+    $ echo 'call f( (re)'
+    call f( (re) (esc) (esc)
+  $ cram literal.t
+  .
+  # Ran 1 tests (1 commands), 0 errors, 0 failures
@@ -0,0 +1,69 @@
+The record command runs commands in a shell and writes them to a new
+test file together with their output and exit codes. The commands
+are read from stdin and their output is shown as they run:
+
+  $ cat > commands << 'EOM'
+  > echo hello
+  > printf 'a\tb'
+  > false
+  > pwd
+  > echo foo \
+  >   bar
+  > EOM
+  $ cram record new.t < commands
+  hello
+  a\tb (esc)
+  [1]
+  /tmp/cram-*/new (glob)
+  foo bar
+  # Recorded 5 commands in new.t
+
+The output is escaped and temporary paths are replaced by their
+placeholders:
+
+  $ cat new.t
+    $ echo hello
+    hello
+    $ printf 'a\tb'
+    a\tb (no-eol) (esc)
+    $ false
+    [1]
+    $ pwd
+    $TESTTMP
+    $ echo foo \
+    >   bar
+    foo bar
+
+The recorded test passes:
+
+  $ cram new.t
+  .
+  # Ran 1 tests (5 commands), 0 errors, 0 failures
+
+The output is written to a terminal, just like when the commands are
+typed by hand:
+
+  $ echo '[ -t 1 ] && echo terminal' | cram record tty.t
+  terminal
+  # Recorded 1 commands in tty.t
+
+The prompts are only shown when stdin is a terminal. Reading the
+commands from /dev/null records nothing:
+
+  $ cram record empty.t < /dev/null
+  # Recorded 0 commands in empty.t
+
+Commands after an exit from the shell are not recorded:
+
+  $ printf 'echo before\nexit\necho after\n' | cram record exit.t
+  before
+  # Recorded 1 commands in exit.t
+  $ cat exit.t
+    $ echo before
+    before
+
+Existing files are never overwritten:
+
+  $ echo 'echo foo' | cram record new.t
+  new.t: file already exists
+  [2]
@@ -0,0 +1,51 @@
+A flaky test which passes on the second attempt:
+
+  $ cat > flaky.t << 'EOM'
+  >   $ n=$(cat "$TESTDIR/count" 2> /dev/null || echo 0)
+  >   $ echo $((n + 1)) | tee "$TESTDIR/count"
+  >   2
+  > EOM
+  $ cram flaky.t
+  F
+  When executing "echo $((n + 1)) | tee \"$TESTDIR/count\"":
+  -2
+  +1
+  # Ran 1 tests (2 commands), 0 errors, 1 failures
+  [1]
+
+With --retries, failed tests are run again in a fresh working
+directory. Tests which pass after a retry are reported as flaky
+together with the failures of the earlier attempts:
+
+  $ rm count
+  $ cram --retries 2 flaky.t
+  f
+  # Attempt 1 of flaky.t failed:
+  When executing "echo $((n + 1)) | tee \"$TESTDIR/count\"":
+  -2
+  +1
+  # Ran 1 tests (2 commands), 0 errors, 0 failures, 1 flaky
+  $ rm count
+  $ cram -v --retries 1 flaky.t
+  f flaky.t: 2 commands passed after 1 retries
+  
+  # Attempt 1 of flaky.t failed:
+  When executing "echo $((n + 1)) | tee \"$TESTDIR/count\"":
+  -2
+  +1
+  # Ran 1 tests (2 commands), 0 errors, 0 failures, 1 flaky
+
+A test which keeps failing is a failure. The failures of the earlier
+attempts are shown before the failures of the last attempt:
+
+  $ cram --retries 1 flaky.t
+  F
+  # Attempt 1 of flaky.t failed:
+  When executing "echo $((n + 1)) | tee \"$TESTDIR/count\"":
+  -2
+  +3
+  When executing "echo $((n + 1)) | tee \"$TESTDIR/count\"":
+  -2
+  +4
+  # Ran 1 tests (2 commands), 0 errors, 1 failures
+  [1]
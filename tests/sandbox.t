@@ -0,0 +1,48 @@
+With --sandbox, the commands run without network access and can only
+write to the working directory:
+
+  $ mkdir tests
+  $ cat > tests/sandbox.t << 'EOM'
+  >   $ echo hello > file && cat file
+  >   hello
+  >   $ touch "$TESTDIR/new" 2> /dev/null || echo read-only
+  >   read-only
+  >   $ touch /etc/new 2> /dev/null || echo read-only
+  >   read-only
+  >   $ ls "$TESTDIR"
+  >   sandbox.t
+  >   $ grep -c : /proc/net/dev
+  >   1
+  > EOM
+  $ cram --sandbox tests/sandbox.t
+  .
+  # Ran 1 tests (5 commands), 0 errors, 0 failures
+
+The sandbox can also be enabled with a directive in the test file:
+
+  $ mkdir directive
+  $ (echo '#cram: sandbox'; cat tests/sandbox.t) > directive/sandbox.t
+  $ cram directive/sandbox.t
+  .
+  # Ran 1 tests (5 commands), 0 errors, 0 failures
+
+Directories outside the system directories, $TESTDIR, and the working
+directory are hidden:
+
+  $ mkdir hidden
+  $ echo "  \$ ls $PWD/hidden" > tests/hidden.t
+  $ cram --sandbox tests/hidden.t
+  F
+  When executing "ls $TESTTMP/hidden":
+  +ls: cannot access '$TESTTMP/hidden': No such file or directory
+  +[2]
+  # Ran 1 tests (1 commands), 0 errors, 1 failures
+  [1]
+
+The commands run with the user ID of the user running Cram:
+
+  $ echo '  $ id -u' > tests/uid.t
+  $ id -u | sed 's/^/  /' >> tests/uid.t
+  $ cram --sandbox tests/uid.t
+  .
+  # Ran 1 tests (1 commands), 0 errors, 0 failures
@@ -0,0 +1,96 @@
+The test files to run can be selected with -k, which matches a part
+of their paths:
+
+  $ mkdir -p lib/sub
+  $ echo '  $ true' > lib/alpha.t
+  $ echo '  $ true' > lib/beta.t
+  $ echo '  $ true' > lib/sub/alphabet.t
+  $ cram -j 1 -v -k alpha lib
+  . lib/alpha.t: 1 commands passed
+  . lib/sub/alphabet.t: 1 commands passed
+  
+  # Ran 2 tests (2 commands), 0 errors, 0 failures
+
+A pattern with glob characters must match the whole path or the name
+of the file:
+
+  $ cram -j 1 -v -k 'b*.t' lib
+  . lib/beta.t: 1 commands passed
+  
+  # Ran 1 tests (1 commands), 0 errors, 0 failures
+  $ cram -j 1 -v -k 'lib/*/*' lib
+  . lib/sub/alphabet.t: 1 commands passed
+  
+  # Ran 1 tests (1 commands), 0 errors, 0 failures
+
+A single command is selected by adding a line number to the file
+name. The commands before it are run, but only the selected command
+is checked:
+
+  $ cat > lines.t << EOM
+  >   \$ echo setup > file
+  >   unexpected
+  >   \$ cat file
+  >   setup
+  >   \$ echo \\
+  >   > wrong
+  >   right
+  > 
+  > After the last command:
+  > 
+  >   \$ exit 1
+  > EOM
+  $ cram lines.t:3
+  .
+  # Ran 1 tests (2 commands), 0 errors, 0 failures
+  $ cram lines.t:4
+  .
+  # Ran 1 tests (2 commands), 0 errors, 0 failures
+
+The line can be any line of the command, including its output:
+
+  $ cram lines.t:6
+  F
+  When executing "echo \\\nwrong":
+  -right
+  +wrong
+  # Ran 1 tests (3 commands), 0 errors, 1 failures
+  [1]
+
+Only the selected command is updated:
+
+  $ cram -u lines.t:7
+  F
+  When executing "echo \\\nwrong":
+  -right
+  +wrong
+  Patched lines.t
+  # Ran 1 tests (3 commands), 0 errors, 1 failures
+  [1]
+  $ head -n 7 lines.t
+    $ echo setup > file
+    unexpected
+    $ cat file
+    setup
+    $ echo \
+    > wrong
+    wrong
+
+A line without a command is an error:
+
+  $ cram lines.t:9
+  lines.t:9: No command on this line
+  E
+  # Ran 1 tests (0 commands), 1 errors, 0 failures
+  [2]
+
+This also works for a command on the last line of a file without a
+final newline:
+
+  $ printf '  $ true\n  $ false\n  [1]\n  $ echo foo' > noeol.t
+  $ cram noeol.t:4
+  F
+  When executing "echo foo":
+  +foo
+  # Ran 1 tests (3 commands), 0 errors, 1 failures
+  [1]
@@ -0,0 +1,89 @@
+Output that changes from run to run can be normalized with
+substitution rules. A rule has the form s/regexp/replacement/ and is
+applied to every line of the actual output:
+
+  $ cat > timing.t << EOM
+  >   $ echo "Finished in 42 ms"
+  >   Finished in N ms
+  > EOM
+  $ cram --substitute 's/[0-9]+ ms/N ms/' timing.t
+  .
+  # Ran 1 tests (1 commands), 0 errors, 0 failures
+
+Without the rule, the output does not match:
+
+  $ cram timing.t
+  F
+  When executing "echo \"Finished in 42 ms\"":
+  -Finished in N ms
+  +Finished in 42 ms
+  # Ran 1 tests (1 commands), 0 errors, 1 failures
+  [1]
+
+The replacement can refer to submatches and any character can be used
+as the delimiter:
+
+  $ cat > version.t << EOM
+  >   $ echo "cram/1.2.3 (linux)"
+  >   cram/X.Y.Z (linux)
+  > EOM
+  $ cram --substitute 's|/[0-9.]+ \((\w+)\)|/X.Y.Z ($1)|' version.t
+  .
+  # Ran 1 tests (1 commands), 0 errors, 0 failures
+
+Rules can also be given in the configuration file:
+
+  $ echo 'substitute = ["s/[0-9]+ ms/N ms/"]' > .cramrc
+  $ cram timing.t
+  .
+  # Ran 1 tests (1 commands), 0 errors, 0 failures
+  $ rm .cramrc
+
+A test file can add its own rules with a directive:
+
+  $ cat > directive.t << EOM
+  > #cram: substitute s/pid [0-9]+/pid PID/
+  >   $ echo "started with pid \$\$"
+  >   started with pid PID
+  > EOM
+  $ cram directive.t
+  .
+  # Ran 1 tests (1 commands), 0 errors, 0 failures
+
+When patching, the output is written after the substitutions:
+
+  $ cat > patch.t << EOM
+  > #cram: substitute s/[0-9]+ ms/N ms/
+  >   $ echo "Finished in 7 ms"
+  > EOM
+  $ yes | cram -i patch.t
+  F
+  When executing "echo \"Finished in 7 ms\"":
+  +Finished in N ms
+  Accept this change [y,n,a,d,q,e,c,?]? Patched patch.t
+  # Ran 1 tests (1 commands), 0 errors, 1 failures
+  [1]
+  $ cat patch.t
+  #cram: substitute s/[0-9]+ ms/N ms/
+    $ echo "Finished in 7 ms"
+    Finished in N ms
+
+Invalid rules are reported:
+
+  $ cram --substitute 's/(/x/' timing.t
+  Substitution "s/(/x/" has invalid pattern: error parsing regexp: missing closing ): `(`
+  [2]
+
+  $ echo '#cram: substitute foo' > invalid.t
+  $ cram invalid.t
+  invalid.t:1: Substitution "foo" must be of the form s/regexp/replacement/
+  E
+  # Ran 1 tests (0 commands), 1 errors, 0 failures
+  [2]
+
+  $ echo '#cram: color always' > unknown.t
+  $ cram unknown.t
+  unknown.t:1: Unknown directive "color"
+  E
+  # Ran 1 tests (0 commands), 1 errors, 0 failures
+  [2]
@@ -0,0 +1,89 @@
+Paths that change from one run to the next are replaced with stable
+placeholders in the output. The working directory of the test becomes
+$TESTTMP:
+
+  $ pwd
+  $TESTTMP
+  $ mkdir foo
+  $ cd foo
+  $ pwd
+  $TESTTMP/foo
+  $ cd ..
+
+The directory with the test file becomes $TESTDIR:
+
+  $ echo $TESTDIR
+  $TESTDIR
+
+The home directory becomes $HOME:
+
+  $ echo $HOME/.profile
+  $HOME/.profile
+
+Only whole path components are replaced, so a sibling directory
+whose name starts with the same characters is left alone:
+
+  $ mkdir ho
+  $ cat > sibling.t << EOM
+  >   \$ echo \$HOME/x \${HOME}use/x
+  > EOM
+  $ HOME=$PWD/ho cram -u sibling.t
+  F
+  When executing "echo $HOME/x ${HOME}use/x":
+  +$HOME/x $TESTDIR/house/x
+  Patched sibling.t
+  # Ran 1 tests (1 commands), 0 errors, 1 failures
+  [1]
+
+The temporary directory holding the working directories of all the
+tests becomes $CRAMTMP:
+
+  $ dirname $PWD
+  $CRAMTMP
+
+Writing the actual paths in the expected output still works:
+
+  $ cat > paths.t << EOM
+  >   $ pwd
+  >   \$TESTTMP
+  >   $ echo \$TESTDIR
+  >   $PWD
+  > EOM
+  $ cram paths.t
+  .
+  # Ran 1 tests (2 commands), 0 errors, 0 failures
+
+The placeholders can be used in (re) lines, where they match the
+placeholder literally:
+
+  $ cat > re.t << 'EOM'
+  >   $ mktemp -p "$PWD"
+  >   $TESTTMP/tmp\.[a-zA-Z0-9]+ (re)
+  >   $ echo $HOME/x
+  >   \$HOME/.+ (re)
+  > EOM
+  $ cram re.t
+  .
+  # Ran 1 tests (2 commands), 0 errors, 0 failures
+
+Patching a test file inserts the placeholders:
+
+  $ cat > patch.t << EOM
+  >   $ echo \$TESTDIR/data.txt
+  >   $ pwd
+  > EOM
+  $ yes | cram -i patch.t
+  F
+  When executing "echo $TESTDIR/data.txt":
+  +$TESTDIR/data.txt
+  Accept this change [y,n,a,d,q,e,c,?]? When executing "pwd":
+  +$TESTTMP
+  Accept this change [y,n,a,d,q,e,c,?]? Patched patch.t
+  # Ran 1 tests (2 commands), 0 errors, 1 failures
+  [1]
+
+  $ cat patch.t
+    $ echo $TESTDIR/data.txt
+    $TESTDIR/data.txt
+    $ pwd
+    $TESTTMP
@@ -0,0 +1,65 @@
+Some commands produce output lines in an unpredictable order. Ending
+the command line with (unordered) makes Cram accept the output lines
+in any order:
+
+  $ printf 'foo\nbar\nbaz\n' (unordered)
+  baz
+  foo
+  bar
+
+Each line is still matched on its own, so patterns can be used:
+
+  $ printf 'b.txt\na.txt\nc.log\n' (unordered)
+  *.log (glob)
+  *.txt (glob)
+  *.txt (glob)
+
+Missing or extra lines are still failures:
+
+  $ cat > test.t << EOM
+  >   $ printf 'foo\nbar\nnew\n' (unordered)
+  >   bar
+  >   gone
+  >   foo
+  > EOM
+  $ cram test.t
+  F
+  When executing "printf 'foo\\nbar\\nnew\\n'":
+  -bar
+  -gone
+   foo
+  +bar
+  +new
+  # Ran 1 tests (1 commands), 0 errors, 1 failures
+  [1]
+
+When patching, the expected lines that matched keep their order and
+the new lines are added after them:
+
+  $ yes | cram -i test.t > /dev/null
+  # Ran 1 tests (1 commands), 0 errors, 1 failures
+  [1]
+  $ cat test.t
+    $ printf 'foo\nbar\nnew\n' (unordered)
+    bar
+    foo
+    new
+  $ cram test.t
+  .
+  # Ran 1 tests (1 commands), 0 errors, 0 failures
+
+The expected order is also kept if only the exit code changed:
+
+  $ cat > exit.t << EOM
+  >   $ printf 'foo\nbar\n'; false (unordered)
+  >   bar
+  >   foo
+  > EOM
+  $ yes | cram -i exit.t > /dev/null
+  # Ran 1 tests (1 commands), 0 errors, 1 failures
+  [1]
+  $ cat exit.t
+    $ printf 'foo\nbar\n'; false (unordered)
+    bar
+    foo
+    [1]
@@ -0,0 +1,166 @@
+The --update flag accepts all changes without asking:
+
+  $ cat > update.t << EOM
+  >   $ echo foo
+  >   bar
+  >   $ echo new
+  >   $ (exit 3)
+  > EOM
+  $ cram --update update.t
+  F
+  When executing "echo foo":
+  -bar
+  +foo
+  When executing "echo new":
+  +new
+  When executing "(exit 3)":
+  +[3]
+  Patched update.t
+  # Ran 1 tests (3 commands), 0 errors, 1 failures
+  [1]
+  $ cat update.t
+    $ echo foo
+    foo
+    $ echo new
+    new
+    $ (exit 3)
+    [3]
+  $ cram update.t
+  .
+  # Ran 1 tests (3 commands), 0 errors, 0 failures
+
+The --fill-empty flag only adds output to commands which have no
+expected output and no exit code. Other failures are left alone, so
+new commands can be bootstrapped without accepting regressions:
+
+  $ cat > fill.t << EOM
+  >   $ echo foo
+  >   bar
+  >   $ echo new
+  >   $ false
+  >   $ (exit 3)
+  >   [1]
+  > EOM
+  $ cram --fill-empty fill.t
+  F
+  When executing "echo foo":
+  -bar
+  +foo
+  When executing "echo new":
+  +new
+  When executing "false":
+  +[1]
+  When executing "(exit 3)":
+  -[1]
+  +[3]
+  Patched fill.t
+  # Ran 1 tests (4 commands), 0 errors, 1 failures
+  [1]
+  $ cat fill.t
+    $ echo foo
+    bar
+    $ echo new
+    new
+    $ false
+    [1]
+    $ (exit 3)
+    [1]
+
+Combined with --interactive, the remaining changes are prompted for:
+
+  $ printf 'y\nn\n' | cram --fill-empty --interactive fill.t
+  F
+  When executing "echo foo":
+  -bar
+  +foo
+  Accept this change [y,n,a,d,q,e,c,?]? When executing "(exit 3)":
+  -[1]
+  +[3]
+  Accept this change [y,n,a,d,q,e,c,?]? Patched fill.t
+  # Ran 1 tests (4 commands), 0 errors, 1 failures
+  [1]
+  $ cat fill.t
+    $ echo foo
+    foo
+    $ echo new
+    new
+    $ false
+    [1]
+    $ (exit 3)
+    [1]
+
+Patched files keep their permissions and no temporary files are left
+behind:
+
+  $ printf '  $ echo foo\n' > mode.t
+  $ chmod 640 mode.t
+  $ cram --update mode.t > /dev/null
+  # Ran 1 tests (1 commands), 0 errors, 1 failures
+  [1]
+  $ ls -l mode.t | cut -c 1-10
+  -rw-r-----
+  $ ls -a | grep mode
+  mode.t
+
+The --backup flag keeps the original file:
+
+  $ printf '  $ echo foo\n  bar\n' > backup.t
+  $ cram --update --backup backup.t > /dev/null
+  # Ran 1 tests (1 commands), 0 errors, 1 failures
+  [1]
+  $ cat backup.t.orig
+    $ echo foo
+    bar
+  $ cat backup.t
+    $ echo foo
+    foo
+
+Symlinks to test files are followed:
+
+  $ mkdir real
+  $ printf '  $ echo foo\n' > real/link.t
+  $ ln -s real/link.t link.t
+  $ cram --update link.t > /dev/null
+  # Ran 1 tests (1 commands), 0 errors, 1 failures
+  [1]
+  $ test -L link.t && cat real/link.t
+    $ echo foo
+    foo
+
+A test file which cannot be patched is an error. Here the test
+rewrites itself, so the command to patch is no longer there:
+
+  $ cat > self.t << 'EOM'
+  >   $ echo '  $ echo changed' > $TESTDIR/self.t
+  >   $ echo foo
+  > EOM
+  $ cram --update self.t
+  F
+  When executing "echo foo":
+  +foo
+  Could not patch self.t: No command with output on line 3
+  # Ran 1 tests (2 commands), 0 errors, 1 failures
+  [2]
+  $ cat self.t
+    $ echo changed
+
+The remaining test files are still patched:
+
+  $ cp self.t other.t
+  $ cat > self.t << 'EOM'
+  >   $ echo '  $ echo changed' > $TESTDIR/self.t
+  >   $ echo foo
+  > EOM
+  $ cram --update -j 1 self.t other.t
+  FF
+  When executing "echo foo":
+  +foo
+  Could not patch self.t: No command with output on line 3
+  When executing "echo changed":
+  +changed
+  Patched other.t
+  # Ran 2 tests (3 commands), 0 errors, 2 failures
+  [2]
+  $ cat other.t
+    $ echo changed
+    changed
@@ -27,7 +27,7 @@ Tests with errors have the errors shown:
 
   $ echo "  > bad" > error.t
   $ cram -v error.t
-  E error.t:0: Continuation line "  > bad\n" has no command
+  E error.t:1: Continuation line "  > bad\n" has no command
   
   # Ran 1 tests (0 commands), 1 errors, 0 failures
   [2]